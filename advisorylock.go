@@ -0,0 +1,257 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LockScope selects how an advisory lock is held on its dedicated session.
+type LockScope int
+
+const (
+	// SessionLock holds the lock with pg_advisory_lock until it is released
+	// or the session ends.
+	SessionLock LockScope = iota
+	// TxLock holds the lock with pg_advisory_xact_lock inside an open
+	// transaction, so it is released by the rollback that ends the lock even
+	// if no unlock call is ever issued.
+	TxLock
+)
+
+func (s LockScope) String() string {
+	switch s {
+	case SessionLock:
+		return "session"
+	case TxLock:
+		return "transaction"
+	}
+	return fmt.Sprintf("LockScope(%d)", int(s))
+}
+
+// ErrLockReleased is returned by AdvisoryLock.Ping once the lock has been
+// released or lost.
+var ErrLockReleased = errors.New("neon: advisory lock released")
+
+// LockKey hashes name to the int64 advisory lock key used by TryLock, Lock,
+// and WithLock (64-bit FNV-1a). It is stable across processes and releases,
+// so other tools can compute the same key.
+func LockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// AdvisoryLock is an advisory lock held on a dedicated direct session. It is
+// released by Release, when the context used to acquire it is done, or when
+// the session ends. AdvisoryLock is safe for concurrent use.
+type AdvisoryLock struct {
+	name  string
+	key   int64
+	scope LockScope
+
+	mu       sync.Mutex
+	conn     *pgx.Conn
+	tx       pgx.Tx
+	released bool
+	done     chan struct{}
+	stop     func() bool
+}
+
+// Name returns the lock name passed to TryLock or Lock.
+func (l *AdvisoryLock) Name() string { return l.name }
+
+// Key returns the advisory lock key, LockKey(Name()).
+func (l *AdvisoryLock) Key() int64 { return l.key }
+
+// Scope returns how the lock is held.
+func (l *AdvisoryLock) Scope() LockScope { return l.scope }
+
+// Done returns a channel that is closed once the lock is released or lost.
+func (l *AdvisoryLock) Done() <-chan struct{} { return l.done }
+
+// Ping checks that the lock's session is still alive, and with it the lock.
+// If the session is gone the lock is treated as lost: its connection is
+// closed, Done is closed, and the error is returned. After release, Ping
+// returns ErrLockReleased.
+func (l *AdvisoryLock) Ping(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return ErrLockReleased
+	}
+	if err := l.conn.Ping(ctx); err != nil {
+		l.closeLocked()
+		return &SafeError{msg: fmt.Sprintf("neon: advisory lock %q lost", l.name), cause: err}
+	}
+	return nil
+}
+
+// Release unlocks and closes the dedicated session. It is safe to call more
+// than once; later calls return nil. The lock is released even when an error
+// is returned, because the session is closed regardless.
+func (l *AdvisoryLock) Release(ctx context.Context) error {
+	if l.stop != nil {
+		l.stop()
+	}
+	return l.release(ctx)
+}
+
+func (l *AdvisoryLock) release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return nil
+	}
+
+	var err error
+	if l.scope == TxLock {
+		err = l.tx.Rollback(ctx)
+	} else {
+		var unlocked bool
+		err = l.conn.QueryRow(ctx, "SELECT pg_advisory_unlock($1::bigint)", l.key).Scan(&unlocked)
+		if err == nil && !unlocked {
+			err = errors.New("lock was not held by this session")
+		}
+	}
+	l.closeLocked()
+	if err != nil {
+		return &SafeError{msg: fmt.Sprintf("neon: release advisory lock %q failed", l.name), cause: err}
+	}
+	return nil
+}
+
+// closeLocked ends the session, which releases any lock it still holds.
+func (l *AdvisoryLock) closeLocked() {
+	closeCtx, cancel := context.WithTimeout(context.Background(), defaultRollbackTimeout)
+	defer cancel()
+	_ = l.conn.Close(closeCtx)
+	l.released = true
+	close(l.done)
+}
+
+// TryLock acquires the advisory lock for name without waiting. It reports
+// false, with a nil error, when another session holds the lock.
+//
+// The lock lives on a new session opened through DirectURL, since session
+// state does not survive a transaction-mode pooler; TryLock refuses to run
+// when DirectURL points at a pooler. The lock is released when ctx is done
+// or Release is called, whichever comes first.
+func (p *Pool) TryLock(ctx context.Context, name string, scope LockScope) (*AdvisoryLock, bool, error) {
+	return p.acquireLock(ctx, name, scope, true)
+}
+
+// Lock acquires the advisory lock for name, waiting until it is available or
+// ctx is done. See TryLock for the session and release rules.
+func (p *Pool) Lock(ctx context.Context, name string, scope LockScope) (*AdvisoryLock, error) {
+	l, _, err := p.acquireLock(ctx, name, scope, false)
+	return l, err
+}
+
+// WithLock runs fn while holding the advisory lock for name, waiting for the
+// lock as Lock does, and releases it when fn returns. fn's error is returned
+// unchanged; a release failure is returned only when fn succeeded.
+func (p *Pool) WithLock(ctx context.Context, name string, scope LockScope, fn func(ctx context.Context) error) (err error) {
+	l, err := p.Lock(ctx, name, scope)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), defaultRollbackTimeout)
+		defer cancel()
+		if releaseErr := l.Release(releaseCtx); err == nil {
+			err = releaseErr
+		}
+	}()
+	return fn(ctx)
+}
+
+func (p *Pool) acquireLock(ctx context.Context, name string, scope LockScope, try bool) (*AdvisoryLock, bool, error) {
+	if scope != SessionLock && scope != TxLock {
+		return nil, false, fmt.Errorf("neon: unknown advisory lock scope %d", int(scope))
+	}
+	connCfg, err := p.lockSessionConfig()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := pgx.ConnectConfig(ctx, connCfg)
+	if err != nil {
+		return nil, false, &SafeError{msg: "neon: advisory lock session connect failed", cause: err}
+	}
+
+	l := &AdvisoryLock{name: name, key: LockKey(name), scope: scope, conn: conn, done: make(chan struct{})}
+	acquired, err := l.acquire(ctx, try)
+	if err != nil || !acquired {
+		closeCtx, cancel := context.WithTimeout(context.Background(), defaultRollbackTimeout)
+		defer cancel()
+		_ = conn.Close(closeCtx)
+		if err != nil {
+			return nil, false, &SafeError{msg: fmt.Sprintf("neon: acquire advisory lock %q failed", name), cause: err}
+		}
+		return nil, false, nil
+	}
+
+	l.stop = context.AfterFunc(ctx, func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), defaultRollbackTimeout)
+		defer cancel()
+		_ = l.release(releaseCtx)
+	})
+	return l, true, nil
+}
+
+func (l *AdvisoryLock) acquire(ctx context.Context, try bool) (bool, error) {
+	var q DBTX = l.conn
+	lockSQL, tryLockSQL := "SELECT pg_advisory_lock($1::bigint)", "SELECT pg_try_advisory_lock($1::bigint)"
+	if l.scope == TxLock {
+		tx, err := l.conn.Begin(ctx)
+		if err != nil {
+			return false, err
+		}
+		l.tx, q = tx, tx
+		lockSQL, tryLockSQL = "SELECT pg_advisory_xact_lock($1::bigint)", "SELECT pg_try_advisory_xact_lock($1::bigint)"
+	}
+
+	if !try {
+		_, err := q.Exec(ctx, lockSQL, l.key)
+		return err == nil, err
+	}
+	var acquired bool
+	err := q.QueryRow(ctx, tryLockSQL, l.key).Scan(&acquired)
+	return acquired, err
+}
+
+// lockSessionConfig returns the connection config for a dedicated lock
+// session on DirectURL. Dial settings (WithWebSocket) and the tracer are
+// carried over from the pool.
+func (p *Pool) lockSessionConfig() (*pgx.ConnConfig, error) {
+	errPooler := errors.New("neon: advisory locks require a direct (non-pooler) connection; set Config.DirectURL to the direct endpoint")
+
+	connCfg, err := pgx.ParseConfig(p.directURL)
+	if err != nil {
+		// SECURITY: do not forward parse errors that may include the direct DSN.
+		return nil, errors.New("neon: invalid DirectURL (must be a valid pgx DSN with sslmode=require or stricter)")
+	}
+	if isNeonPoolerHost(connCfg.Host) {
+		return nil, errPooler
+	}
+	if err := validateTLSPosture("DirectURL", connCfg); err != nil {
+		return nil, err
+	}
+	poolCfg := p.pool.Config().ConnConfig
+	if p.poolerMode && connCfg.Host == poolCfg.Host && connCfg.Port == poolCfg.Port {
+		// ForcePoolerMode with a DirectURL on the same (pooler) endpoint.
+		return nil, errPooler
+	}
+
+	connCfg.DialFunc = poolCfg.DialFunc
+	connCfg.LookupFunc = poolCfg.LookupFunc
+	connCfg.Tracer = poolCfg.Tracer
+	if connCfg.ConnectTimeout == 0 {
+		connCfg.ConnectTimeout = poolCfg.ConnectTimeout
+	}
+	return connCfg, nil
+}
@@ -0,0 +1,320 @@
+package neon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAPIBaseURL      = "https://console.neon.tech/api/v2"
+	defaultAPIDatabaseName = "neondb"
+	defaultAPIRoleName     = "neondb_owner"
+	defaultAPITimeout      = 30 * time.Second
+	maxAPIResponseBytes    = 4 << 20
+)
+
+// APIConfig configures an APIClient.
+type APIConfig struct {
+	// APIKey is the Neon API key (typically NEON_API_KEY). It is secret
+	// material and is never included in errors.
+	APIKey string
+
+	// ProjectID is the Neon project all branch operations apply to.
+	ProjectID string
+
+	// DatabaseName and RoleName select the connection strings returned by
+	// BranchURLs. Defaults: "neondb" and "neondb_owner".
+	DatabaseName string
+	RoleName     string
+
+	// BaseURL overrides the Neon API base URL. It must use https.
+	// Default: https://console.neon.tech/api/v2.
+	BaseURL string
+
+	// HTTPClient overrides the HTTP client. Default: a client with a 30s
+	// timeout.
+	HTTPClient *http.Client
+}
+
+// APIClient is a minimal Neon HTTP API client for branch management.
+//
+// It implements BranchAPI and BranchLister. Errors are SafeError values whose
+// outer strings include the operation and HTTP status but never credentials.
+type APIClient struct {
+	apiKey     string
+	projectID  string
+	database   string
+	role       string
+	baseURL    string
+	httpClient *http.Client
+}
+
+var (
+	_ BranchAPI    = (*APIClient)(nil)
+	_ BranchLister = (*APIClient)(nil)
+)
+
+// NewAPIClient validates cfg and returns an APIClient.
+func NewAPIClient(cfg APIConfig) (*APIClient, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("neon: APIConfig.APIKey is required")
+	}
+	if cfg.ProjectID == "" {
+		return nil, errors.New("neon: APIConfig.ProjectID is required")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultAPIBaseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("neon: APIConfig.BaseURL must be an https URL")
+	}
+
+	c := &APIClient{
+		apiKey:     cfg.APIKey,
+		projectID:  cfg.ProjectID,
+		database:   cfg.DatabaseName,
+		role:       cfg.RoleName,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: cfg.HTTPClient,
+	}
+	if c.database == "" {
+		c.database = defaultAPIDatabaseName
+	}
+	if c.role == "" {
+		c.role = defaultAPIRoleName
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: defaultAPITimeout}
+	}
+
+	return c, nil
+}
+
+type apiBranch struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ParentID string `json:"parent_id"`
+}
+
+type apiEndpoint struct {
+	Host     string `json:"host"`
+	BranchID string `json:"branch_id"`
+	Type     string `json:"type"`
+}
+
+// CreateBranch creates a branch with a read-write compute endpoint. An empty
+// parentID branches from the project's default branch.
+func (c *APIClient) CreateBranch(ctx context.Context, name, parentID string) (*Branch, error) {
+	var req struct {
+		Branch struct {
+			Name     string `json:"name,omitempty"`
+			ParentID string `json:"parent_id,omitempty"`
+		} `json:"branch"`
+		Endpoints []apiEndpoint `json:"endpoints"`
+	}
+	req.Branch.Name = name
+	req.Branch.ParentID = parentID
+	req.Endpoints = []apiEndpoint{{Type: "read_write"}}
+
+	var resp struct {
+		Branch    apiBranch     `json:"branch"`
+		Endpoints []apiEndpoint `json:"endpoints"`
+	}
+	if err := c.do(ctx, "create branch", http.MethodPost, c.projectPath("branches"), nil, req, &resp); err != nil {
+		return nil, err
+	}
+
+	b := &Branch{ID: resp.Branch.ID, Name: resp.Branch.Name, ParentID: resp.Branch.ParentID}
+	for _, ep := range resp.Endpoints {
+		if ep.Type == "read_write" {
+			b.Host = ep.Host
+			break
+		}
+	}
+	return b, nil
+}
+
+// ListBranches returns all project branches with their read-write endpoint
+// hosts, following the API's pagination cursor until the last page.
+func (c *APIClient) ListBranches(ctx context.Context) ([]Branch, error) {
+	var branches []apiBranch
+	seen := map[string]bool{}
+	for cursor := ""; ; {
+		var q url.Values
+		if cursor != "" {
+			q = url.Values{"cursor": {cursor}}
+		}
+		var page struct {
+			Branches   []apiBranch `json:"branches"`
+			Pagination *struct {
+				Next string `json:"next"`
+			} `json:"pagination"`
+		}
+		if err := c.do(ctx, "list branches", http.MethodGet, c.projectPath("branches"), q, nil, &page); err != nil {
+			return nil, err
+		}
+		branches = append(branches, page.Branches...)
+
+		// The last page has no next cursor, is empty, or repeats a cursor.
+		if page.Pagination == nil || page.Pagination.Next == "" || len(page.Branches) == 0 || seen[page.Pagination.Next] {
+			break
+		}
+		cursor = page.Pagination.Next
+		seen[cursor] = true
+	}
+
+	var endpointsResp struct {
+		Endpoints []apiEndpoint `json:"endpoints"`
+	}
+	if err := c.do(ctx, "list endpoints", http.MethodGet, c.projectPath("endpoints"), nil, nil, &endpointsResp); err != nil {
+		return nil, err
+	}
+	hosts := make(map[string]string, len(endpointsResp.Endpoints))
+	for _, ep := range endpointsResp.Endpoints {
+		if ep.Type == "read_write" {
+			hosts[ep.BranchID] = ep.Host
+		}
+	}
+
+	out := make([]Branch, 0, len(branches))
+	for _, b := range branches {
+		out = append(out, Branch{ID: b.ID, Name: b.Name, ParentID: b.ParentID, Host: hosts[b.ID]})
+	}
+	return out, nil
+}
+
+// DeleteBranch permanently deletes a branch and its data.
+func (c *APIClient) DeleteBranch(ctx context.Context, branchID string) error {
+	return c.do(ctx, "delete branch", http.MethodDelete, c.projectPath("branches", branchID), nil, nil, nil)
+}
+
+// ResetBranch restores a branch to the latest state of its parent, discarding
+// all changes made on the branch.
+func (c *APIClient) ResetBranch(ctx context.Context, branchID, parentID string) error {
+	if parentID == "" {
+		return errors.New("neon: reset branch requires a parent branch ID")
+	}
+	req := struct {
+		SourceBranchID string `json:"source_branch_id"`
+	}{SourceBranchID: parentID}
+	return c.do(ctx, "reset branch", http.MethodPost, c.projectPath("branches", branchID, "restore"), nil, req, nil)
+}
+
+// BranchURLs resolves the pooled and direct connection strings for a branch
+// using the configured database and role. Both values are secret material.
+func (c *APIClient) BranchURLs(ctx context.Context, branchID string) (BranchURLs, error) {
+	var urls BranchURLs
+	for _, pooled := range []bool{true, false} {
+		q := url.Values{}
+		q.Set("branch_id", branchID)
+		q.Set("database_name", c.database)
+		q.Set("role_name", c.role)
+		q.Set("pooled", fmt.Sprint(pooled))
+
+		var resp struct {
+			URI string `json:"uri"`
+		}
+		if err := c.do(ctx, "get connection uri", http.MethodGet, c.projectPath("connection_uri"), q, nil, &resp); err != nil {
+			return BranchURLs{}, err
+		}
+		if resp.URI == "" {
+			return BranchURLs{}, errors.New("neon: api get connection uri returned an empty uri")
+		}
+		if pooled {
+			urls.Pooled = resp.URI
+		} else {
+			urls.Direct = resp.URI
+		}
+	}
+	return urls, nil
+}
+
+func (c *APIClient) projectPath(elems ...string) string {
+	p := "/projects/" + url.PathEscape(c.projectID)
+	for _, e := range elems {
+		p += "/" + url.PathEscape(e)
+	}
+	return p
+}
+
+// do performs a JSON API call. op names the operation in safe outer errors.
+func (c *APIClient) do(ctx context.Context, op, method, path string, query url.Values, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return &SafeError{msg: fmt.Sprintf("neon: api %s failed (encode request)", op), cause: err}
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return &SafeError{msg: fmt.Sprintf("neon: api %s failed (build request)", op), cause: err}
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &SafeError{msg: fmt.Sprintf("neon: api %s failed (request error)", op), cause: err}
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAPIResponseBytes))
+	if err != nil {
+		return &SafeError{msg: fmt.Sprintf("neon: api %s failed (read response)", op), cause: err}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return &SafeError{
+			msg:   fmt.Sprintf("neon: api %s failed (status=%d)", op, resp.StatusCode),
+			cause: &APIError{StatusCode: resp.StatusCode, Code: apiErr.Code, Message: apiErr.Message},
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return &SafeError{msg: fmt.Sprintf("neon: api %s failed (decode response)", op), cause: err}
+	}
+	return nil
+}
+
+// APIError is the cause wrapped by APIClient errors for non-2xx responses.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("neon api: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("neon api: status %d: %s", e.StatusCode, e.Message)
+}
@@ -0,0 +1,245 @@
+package neon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testAPIKey = "napi_supersecretkey"
+
+func newTestAPIClient(t *testing.T, handler http.HandlerFunc) *APIClient {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+
+	c, err := NewAPIClient(APIConfig{
+		APIKey:     testAPIKey,
+		ProjectID:  "proj-123",
+		BaseURL:    srv.URL + "/api/v2",
+		HTTPClient: srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("NewAPIClient error=%v", err)
+	}
+	return c
+}
+
+func TestNewAPIClient_Validation(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		cfg  APIConfig
+		want string
+	}{
+		{name: "missing-key", cfg: APIConfig{ProjectID: "p"}, want: "APIKey is required"},
+		{name: "missing-project", cfg: APIConfig{APIKey: "k"}, want: "ProjectID is required"},
+		{name: "plaintext-base-url", cfg: APIConfig{APIKey: "k", ProjectID: "p", BaseURL: "http://console.neon.tech/api/v2"}, want: "must be an https URL"},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewAPIClient(tc.cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("error=%v, want containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestAPIClient_CreateBranch(t *testing.T) {
+	t.Parallel()
+
+	c := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/projects/proj-123/branches" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer "+testAPIKey {
+			t.Errorf("Authorization=%q", got)
+		}
+		var body struct {
+			Branch struct {
+				Name     string `json:"name"`
+				ParentID string `json:"parent_id"`
+			} `json:"branch"`
+			Endpoints []struct {
+				Type string `json:"type"`
+			} `json:"endpoints"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if body.Branch.Name != "feature/x" || body.Branch.ParentID != "br-main" {
+			t.Errorf("unexpected branch body: %+v", body.Branch)
+		}
+		if len(body.Endpoints) != 1 || body.Endpoints[0].Type != "read_write" {
+			t.Errorf("unexpected endpoints body: %+v", body.Endpoints)
+		}
+		_, _ = w.Write([]byte(`{"branch":{"id":"br-new","name":"feature/x","parent_id":"br-main"},
+			"endpoints":[{"host":"ep-new.us-east-2.aws.neon.tech","branch_id":"br-new","type":"read_write"}]}`))
+	})
+
+	b, err := c.CreateBranch(context.Background(), "feature/x", "br-main")
+	if err != nil {
+		t.Fatalf("CreateBranch error=%v", err)
+	}
+	want := Branch{ID: "br-new", Name: "feature/x", ParentID: "br-main", Host: "ep-new.us-east-2.aws.neon.tech"}
+	if *b != want {
+		t.Fatalf("branch=%+v, want %+v", *b, want)
+	}
+}
+
+func TestAPIClient_ListBranchesJoinsEndpointHosts(t *testing.T) {
+	t.Parallel()
+
+	c := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/projects/proj-123/branches":
+			_, _ = w.Write([]byte(`{"branches":[{"id":"br-main","name":"main"},{"id":"br-f","name":"f","parent_id":"br-main"}]}`))
+		case "/api/v2/projects/proj-123/endpoints":
+			_, _ = w.Write([]byte(`{"endpoints":[
+				{"host":"ep-main.neon.tech","branch_id":"br-main","type":"read_write"},
+				{"host":"ep-ro.neon.tech","branch_id":"br-f","type":"read_only"}]}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	})
+
+	branches, err := c.ListBranches(context.Background())
+	if err != nil {
+		t.Fatalf("ListBranches error=%v", err)
+	}
+	if len(branches) != 2 {
+		t.Fatalf("branches=%d, want 2", len(branches))
+	}
+	if branches[0].Host != "ep-main.neon.tech" {
+		t.Fatalf("main host=%q", branches[0].Host)
+	}
+	if branches[1].Host != "" || branches[1].ParentID != "br-main" {
+		t.Fatalf("unexpected branch[1]=%+v", branches[1])
+	}
+}
+
+func TestAPIClient_ListBranchesFollowsPagination(t *testing.T) {
+	t.Parallel()
+
+	pages := map[string]string{
+		"":   `{"branches":[{"id":"br-1","name":"main"}],"pagination":{"next":"c1"}}`,
+		"c1": `{"branches":[{"id":"br-2","name":"feature/a"}],"pagination":{"next":"c2"}}`,
+		"c2": `{"branches":[],"pagination":{"next":"c2"}}`,
+	}
+	var cursors []string
+	c := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/projects/proj-123/branches":
+			cursor := r.URL.Query().Get("cursor")
+			cursors = append(cursors, cursor)
+			_, _ = w.Write([]byte(pages[cursor]))
+		case "/api/v2/projects/proj-123/endpoints":
+			_, _ = w.Write([]byte(`{"endpoints":[{"host":"ep-a.neon.tech","branch_id":"br-2","type":"read_write"}]}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	})
+
+	branches, err := c.ListBranches(context.Background())
+	if err != nil {
+		t.Fatalf("ListBranches error=%v", err)
+	}
+	if got := strings.Join(cursors, ","); got != ",c1,c2" {
+		t.Fatalf("cursors=%q", got)
+	}
+	if len(branches) != 2 || branches[1].Name != "feature/a" || branches[1].Host != "ep-a.neon.tech" {
+		t.Fatalf("branches=%+v", branches)
+	}
+}
+
+func TestAPIClient_DeleteAndResetBranch(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	c := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/restore") {
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["source_branch_id"] != "br-main" {
+				t.Errorf("restore body=%v", body)
+			}
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	if err := c.DeleteBranch(context.Background(), "br-f"); err != nil {
+		t.Fatalf("DeleteBranch error=%v", err)
+	}
+	if err := c.ResetBranch(context.Background(), "br-f", "br-main"); err != nil {
+		t.Fatalf("ResetBranch error=%v", err)
+	}
+	if err := c.ResetBranch(context.Background(), "br-f", ""); err == nil {
+		t.Fatal("expected error for empty parent")
+	}
+
+	want := "DELETE /api/v2/projects/proj-123/branches/br-f,POST /api/v2/projects/proj-123/branches/br-f/restore"
+	if got := strings.Join(calls, ","); got != want {
+		t.Fatalf("calls=%q, want %q", got, want)
+	}
+}
+
+func TestAPIClient_BranchURLs(t *testing.T) {
+	t.Parallel()
+
+	c := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("branch_id") != "br-f" || q.Get("database_name") != "neondb" || q.Get("role_name") != "neondb_owner" {
+			t.Errorf("unexpected query %v", q)
+		}
+		host := "ep-f.neon.tech"
+		if q.Get("pooled") == "true" {
+			host = "ep-f-pooler.neon.tech"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"uri": "postgresql://u:p@" + host + "/neondb?sslmode=require"})
+	})
+
+	urls, err := c.BranchURLs(context.Background(), "br-f")
+	if err != nil {
+		t.Fatalf("BranchURLs error=%v", err)
+	}
+	if !strings.Contains(urls.Pooled, "ep-f-pooler.neon.tech") || !strings.Contains(urls.Direct, "@ep-f.neon.tech") {
+		t.Fatal("unexpected pooled/direct URLs")
+	}
+}
+
+func TestAPIClient_ErrorsAreSafe(t *testing.T) {
+	t.Parallel()
+
+	c := newTestAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"code":"not_found","message":"branch not found"}`))
+	})
+
+	err := c.DeleteBranch(context.Background(), "br-missing")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got, want := err.Error(), "neon: api delete branch failed (status=404)"; got != want {
+		t.Fatalf("error=%q, want %q", got, want)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError cause, got %T", errors.Unwrap(err))
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "not_found" || apiErr.Message != "branch not found" {
+		t.Fatalf("unexpected APIError: %+v", apiErr)
+	}
+	if strings.Contains(err.Error(), testAPIKey) {
+		t.Fatal("error leaked API key")
+	}
+}
@@ -0,0 +1,204 @@
+package neon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BatchDB is implemented by DB implementations that can send pgx batches in a
+// single round trip (*Pool and *TestDB). Depend on it, or type-assert a DB to
+// it, where batching is worth the extra interface surface:
+//
+//	if bdb, ok := db.(neon.BatchDB); ok {
+//		return neon.WithBatch(ctx, bdb, batch, nil)
+//	}
+type BatchDB interface {
+	DB
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+var (
+	_ BatchDB = (*Pool)(nil)
+	_ BatchDB = (*TestDB)(nil)
+)
+
+// WithBatch sends b through db, passes the results to fn, and always closes
+// them, which also runs any QueuedQuery callbacks. db is typically a *Pool,
+// or the pgx.Tx inside WithTx so the batch joins the transaction:
+//
+//	err := neon.WithTx(ctx, db, pgx.TxOptions{}, func(tx pgx.Tx) error {
+//		return neon.WithBatch(ctx, tx, batch, nil)
+//	})
+//
+// A nil fn only executes the batch. fn's error is returned unchanged; a
+// failure reported while closing the results is wrapped in a SafeError.
+//
+// Queries are checked with the same pooler-mode rules as Pool.SendBatch.
+func WithBatch(ctx context.Context, db DBTX, b *pgx.Batch, fn func(pgx.BatchResults) error) error {
+	b, err := poolerSafeBatch(b)
+	if err != nil {
+		return err
+	}
+
+	br := db.SendBatch(ctx, b)
+	if fn != nil {
+		if err := fn(br); err != nil {
+			_ = br.Close()
+			return err
+		}
+	}
+	if err := br.Close(); err != nil {
+		return &SafeError{msg: fmt.Sprintf("neon: batch of %d queries failed", b.Len()), cause: err}
+	}
+	return nil
+}
+
+// poolerSafeBatch prepares b for simple-protocol (pooler mode) execution,
+// where pgx joins the queued queries with ";" into one multi-statement query.
+//
+// A queued query that itself contains several statements would shift every
+// later result, so it is rejected (the extended protocol rejects it too). A
+// query ending in a -- comment would comment out the next query, so it gets a
+// trailing newline. b is copied only when a query changes; callbacks
+// registered on its QueuedQuery values are preserved.
+func poolerSafeBatch(b *pgx.Batch) (*pgx.Batch, error) {
+	if b == nil {
+		return &pgx.Batch{}, nil
+	}
+
+	var out *pgx.Batch
+	for i, qq := range b.QueuedQueries {
+		multi, lineComment := scanSQLStatements(qq.SQL)
+		if multi {
+			return nil, fmt.Errorf("neon: batch query %d contains multiple statements; queue each statement separately", i)
+		}
+		if !lineComment {
+			continue
+		}
+		if out == nil {
+			out = &pgx.Batch{QueuedQueries: append([]*pgx.QueuedQuery(nil), b.QueuedQueries...)}
+		}
+		c := *qq
+		c.SQL += "\n"
+		out.QueuedQueries[i] = &c
+	}
+	if out == nil {
+		return b, nil
+	}
+	return out, nil
+}
+
+// scanSQLStatements reports whether sql contains a top-level ";" followed by
+// another statement, and whether it ends inside a -- line comment. It skips
+// string literals (including E'...' escapes), quoted identifiers, block
+// comments, and dollar-quoted strings.
+func scanSQLStatements(sql string) (multi, trailingLineComment bool) {
+	sawTerminator := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == ';':
+			sawTerminator = true
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			continue
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return multi, true
+			}
+			i += end
+			continue
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			i = skipBlockComment(sql, i)
+			continue
+		}
+
+		if sawTerminator {
+			multi = true
+		}
+		switch {
+		case c == '\'':
+			escapes := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isIdentByte(sql[i-2]))
+			i = skipQuoted(sql, i, '\'', escapes)
+		case c == '"':
+			i = skipQuoted(sql, i, '"', false)
+		case c == '$':
+			i = skipDollarQuoted(sql, i)
+		case isIdentByte(c):
+			for i+1 < len(sql) && (isIdentByte(sql[i+1]) || sql[i+1] == '$') {
+				i++
+			}
+		}
+	}
+	return multi, false
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// skipQuoted returns the index of the closing quote for the literal starting
+// at sql[start], treating doubled quotes (and backslashes, when escapes is
+// set) as escapes.
+func skipQuoted(sql string, start int, quote byte, escapes bool) int {
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(sql)
+}
+
+// skipBlockComment returns the index of the final '/' of the (possibly
+// nested) block comment starting at sql[start].
+func skipBlockComment(sql string, start int) int {
+	depth := 0
+	for i := start; i+1 < len(sql); i++ {
+		switch {
+		case sql[i] == '/' && sql[i+1] == '*':
+			depth++
+			i++
+		case sql[i] == '*' && sql[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(sql)
+}
+
+// skipDollarQuoted returns the end index of a $tag$...$tag$ string starting
+// at sql[start], or start when the '$' is a positional parameter.
+func skipDollarQuoted(sql string, start int) int {
+	end := start + 1
+	for end < len(sql) && sql[end] != '$' {
+		if !isIdentByte(sql[end]) || (end == start+1 && sql[end] >= '0' && sql[end] <= '9') {
+			return end - 1
+		}
+		end++
+	}
+	if end >= len(sql) {
+		return len(sql)
+	}
+	tag := sql[start : end+1]
+	closeAt := strings.Index(sql[end+1:], tag)
+	if closeAt < 0 {
+		return len(sql)
+	}
+	return end + 1 + closeAt + len(tag) - 1
+}
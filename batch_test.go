@@ -0,0 +1,227 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestScanSQLStatements(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		sql         string
+		multi, line bool
+	}{
+		{"SELECT 1", false, false},
+		{"SELECT 1;", false, false},
+		{"SELECT 1;  \n", false, false},
+		{"SELECT 1; SELECT 2", true, false},
+		{"SELECT ';' AS semi", false, false},
+		{"SELECT 'it''s; fine'", false, false},
+		{`SELECT E'a\'; b'`, false, false},
+		{`SELECT "odd;name" FROM t`, false, false},
+		{"SELECT $$; not a statement$$, $tag$ ; $tag$", false, false},
+		{"SELECT $1::int; DELETE FROM t", true, false},
+		{"SELECT price$ FROM t", false, false},
+		{"SELECT 1 /* ; /* nested ; */ */", false, false},
+		{"-- name: GetProject :one\nSELECT * FROM projects WHERE id = $1", false, false},
+		{"SELECT 1 -- trailing ; comment", false, true},
+		{"SELECT 1; -- only a comment after", false, true},
+		{"SELECT 1 -- c\n; UPDATE t SET x = 1", true, false},
+	}
+	for _, tt := range tests {
+		multi, line := scanSQLStatements(tt.sql)
+		if multi != tt.multi || line != tt.line {
+			t.Errorf("scanSQLStatements(%q) = (%v, %v), want (%v, %v)", tt.sql, multi, line, tt.multi, tt.line)
+		}
+	}
+}
+
+func TestPoolerSafeBatch(t *testing.T) {
+	t.Parallel()
+
+	b := &pgx.Batch{}
+	b.Queue("SELECT 1")
+	var called bool
+	b.Queue("SELECT 2 -- two").Exec(func(pgconn.CommandTag) error { called = true; return nil })
+
+	safe, err := poolerSafeBatch(b)
+	if err != nil {
+		t.Fatalf("poolerSafeBatch error=%v", err)
+	}
+	if safe == b || safe.QueuedQueries[0] != b.QueuedQueries[0] {
+		t.Fatal("expected a copy sharing unchanged queries")
+	}
+	if got := safe.QueuedQueries[1].SQL; got != "SELECT 2 -- two\n" {
+		t.Fatalf("SQL=%q", got)
+	}
+	if b.QueuedQueries[1].SQL != "SELECT 2 -- two" {
+		t.Fatal("caller's batch was modified")
+	}
+	if err := safe.QueuedQueries[1].Fn(NewBatchResults().AddExec(pgconn.NewCommandTag("SELECT 1")).Build()); err != nil || !called {
+		t.Fatalf("callback err=%v called=%v", err, called)
+	}
+
+	clean := &pgx.Batch{}
+	clean.Queue("SELECT 1")
+	if got, _ := poolerSafeBatch(clean); got != clean {
+		t.Fatal("expected an unchanged batch to be returned as is")
+	}
+
+	multi := &pgx.Batch{}
+	multi.Queue("SELECT 1")
+	multi.Queue("UPDATE a SET x = 1; UPDATE b SET x = 1")
+	if _, err := poolerSafeBatch(multi); err == nil || !strings.Contains(err.Error(), "batch query 1 contains multiple statements") {
+		t.Fatalf("multi-statement error=%v", err)
+	}
+}
+
+func TestPool_SendBatchInPoolerMode(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.RejectExtendedProtocol()
+	srv.Handle(`^\s*UPDATE projects SET archived = true WHERE id = +'1'`, 0, fakePGResult{Tag: "UPDATE 1"})
+	srv.Handle(`^\s*SELECT name FROM projects WHERE id = +'2'`, 0, fakePGResult{
+		Columns: []fakePGColumn{{Name: "name", OID: pgtype.TextOID}},
+		Rows:    [][]any{{"Second"}},
+	})
+	pool := connectFakePG(t, Config{ConnectionString: srv.URL("require"), DirectURL: srv.URL("require"), ForcePoolerMode: true})
+	ctx := context.Background()
+
+	b := &pgx.Batch{}
+	b.Queue("UPDATE projects SET archived = true WHERE id = $1 -- archive first", "1")
+	var name string
+	b.Queue("SELECT name FROM projects WHERE id = $1", "2").QueryRow(func(row pgx.Row) error { return row.Scan(&name) })
+
+	if err := WithBatch(ctx, pool, b, func(br pgx.BatchResults) error {
+		tag, err := br.Exec()
+		if err != nil || tag.RowsAffected() != 1 {
+			t.Errorf("Exec tag=%v err=%v", tag, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("WithBatch error=%v", err)
+	}
+	if name != "Second" {
+		t.Fatalf("name=%q, want results aligned after a trailing comment", name)
+	}
+	queries := srv.Queries()
+	if len(queries) != 2 || queries[0].Extended || queries[1].Extended {
+		t.Fatalf("queries=%+v, want two simple-protocol statements", queries)
+	}
+
+	bad := &pgx.Batch{}
+	bad.Queue("UPDATE a SET x = 1; UPDATE b SET x = 1")
+	if err := pool.SendBatch(ctx, bad).Close(); err == nil || !strings.Contains(err.Error(), "multiple statements") {
+		t.Fatalf("multi-statement error=%v", err)
+	}
+	if got := len(srv.Queries()); got != 2 {
+		t.Fatalf("queries=%d, want the rejected batch never sent", got)
+	}
+}
+
+func TestWithBatch_InsideWithTx(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.Handle(`^\s*INSERT INTO audit_log`, 0, fakePGResult{Tag: "INSERT 0 1"})
+	srv.Handle(`^\s*UPDATE projects`, 0, fakePGResult{ErrCode: "23503", ErrMessage: "violates foreign key constraint"})
+	pool := connectFakePG(t, Config{ConnectionString: srv.URL("require"), DirectURL: srv.URL("require"), ForcePoolerMode: true})
+	ctx := context.Background()
+
+	ok := &pgx.Batch{}
+	ok.Queue("INSERT INTO audit_log (action) VALUES ($1)", "a")
+	ok.Queue("INSERT INTO audit_log (action) VALUES ($1)", "b")
+	if err := WithTx(ctx, pool, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		return WithBatch(ctx, tx, ok, nil)
+	}); err != nil {
+		t.Fatalf("WithTx error=%v", err)
+	}
+
+	failing := &pgx.Batch{}
+	failing.Queue("INSERT INTO audit_log (action) VALUES ($1)", "c")
+	failing.Queue("UPDATE projects SET owner_id = $1", "missing")
+	err := WithTx(ctx, pool, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		return WithBatch(ctx, tx, failing, nil)
+	})
+	var safe *SafeError
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &safe) || !errors.As(err, &pgErr) || pgErr.Code != "23503" {
+		t.Fatalf("error=%v, want SafeError wrapping 23503", err)
+	}
+	if strings.Contains(err.Error(), "missing") {
+		t.Fatalf("error leaked args: %v", err)
+	}
+
+	var sqls []string
+	for _, q := range srv.Queries() {
+		sqls = append(sqls, strings.Fields(q.SQL)[0])
+	}
+	if got := strings.Join(sqls, " "); got != "begin INSERT INSERT commit begin INSERT UPDATE rollback" {
+		t.Fatalf("statements=%q", got)
+	}
+}
+
+func TestBatchResultsBuilder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	b := &pgx.Batch{}
+	var tags []string
+	b.Queue("UPDATE a").Exec(func(tag pgconn.CommandTag) error { tags = append(tags, tag.String()); return nil })
+	b.Queue("SELECT id FROM a")
+	b.Queue("DELETE FROM a")
+
+	db := &TestDB{SendBatchFunc: func(_ context.Context, b *pgx.Batch) pgx.BatchResults {
+		return NewBatchResults().
+			AddExec(pgconn.NewCommandTag("UPDATE 2")).
+			AddRows(NewRows([]string{"id"}).AddRow(int64(7))).
+			AddError(boom).
+			BuildFor(b)
+	}}
+
+	var id int64
+	err := WithBatch(ctx, db, b, func(br pgx.BatchResults) error {
+		// Reading the first result directly bypasses its callback.
+		if tag, err := br.Exec(); err != nil || tag.String() != "UPDATE 2" {
+			t.Errorf("Exec tag=%v err=%v", tag, err)
+		}
+		return br.QueryRow().Scan(&id)
+	})
+	if !errors.Is(err, boom) || id != 7 || len(tags) != 0 {
+		t.Fatalf("err=%v id=%d tags=%v", err, id, tags)
+	}
+	db.AssertCalled(t, "SendBatch")
+	db.AssertSQLContains(t, "DELETE FROM a")
+
+	// Close runs callbacks for unread results.
+	br := NewBatchResults().AddExec(pgconn.NewCommandTag("UPDATE 1")).AddExec(pgconn.CommandTag{}).AddExec(pgconn.CommandTag{}).BuildFor(b)
+	if err := br.Close(); err != nil || len(tags) != 1 || tags[0] != "UPDATE 1" {
+		t.Fatalf("Close err=%v tags=%v", err, tags)
+	}
+	if _, err := br.Exec(); err == nil {
+		t.Fatal("expected error reading after Close")
+	}
+
+	mismatch := NewBatchResults().AddExec(pgconn.CommandTag{}).BuildFor(b)
+	if _, err := mismatch.Exec(); err == nil || !strings.Contains(err.Error(), "3 queued queries but 1 results") {
+		t.Fatalf("mismatch err=%v", err)
+	}
+
+	closeErr := errors.New("conn lost")
+	if err := NewBatchResults().WithCloseError(closeErr).Build().Close(); !errors.Is(err, closeErr) {
+		t.Fatalf("close err=%v", err)
+	}
+	if _, err := NewBatchResults().Build().Query(); err == nil || !strings.Contains(err.Error(), "no more results") {
+		t.Fatalf("exhausted err=%v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package neon
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	defaultTestBranchTimeout = 2 * time.Minute
+	testBranchDeleteTimeout  = 30 * time.Second
+	testBranchNameMaxLen     = 48
+)
+
+// Branch describes a Neon branch. It carries no credentials and is safe to
+// log.
+type Branch struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ParentID string `json:"parent_id,omitempty"`
+
+	// Host is the direct (non-pooled) compute endpoint hostname for the branch.
+	// It is empty when the branch has no read-write endpoint.
+	Host string `json:"host,omitempty"`
+}
+
+// BranchURLs holds the connection strings for a single Neon branch.
+// Both values contain credentials and must be treated as secret material.
+type BranchURLs struct {
+	// Pooled is the pooler endpoint URL used for application queries.
+	Pooled string
+	// Direct is the non-pooled endpoint URL used for migrations and
+	// session-level operations.
+	Direct string
+}
+
+// BranchAPI is the subset of the Neon API used by NewTestBranch.
+//
+// Implementations must return errors that are safe to log (no connection
+// strings or API keys in Error()).
+type BranchAPI interface {
+	// CreateBranch creates a branch named name from the parent branch
+	// parentID, including a read-write compute endpoint.
+	CreateBranch(ctx context.Context, name, parentID string) (*Branch, error)
+
+	// BranchURLs resolves the pooled and direct connection strings for a
+	// branch.
+	BranchURLs(ctx context.Context, branchID string) (BranchURLs, error)
+
+	// DeleteBranch permanently deletes a branch and its data.
+	DeleteBranch(ctx context.Context, branchID string) error
+}
+
+// TestBranchConfig configures NewTestBranch.
+type TestBranchConfig struct {
+	// API creates, resolves, and deletes the ephemeral branch.
+	// Required unless LocalDSN is set.
+	API BranchAPI
+
+	// ParentID is the branch ID the ephemeral branch is created from.
+	// Empty uses the project's default branch.
+	ParentID string
+
+	// Migrate runs schema migrations against the branch. It receives the
+	// direct (non-pooled) URL, which contains credentials. Optional.
+	Migrate func(ctx context.Context, directURL string) error
+
+	// LocalDSN enables fake-API mode: no branch is created, and LocalDSN is
+	// used as both the pooled and direct URL. It must still satisfy the TLS
+	// requirements enforced by Connect (sslmode=require or stricter).
+	LocalDSN string
+
+	// Pool carries pool tuning (MaxConns, ConnectTimeout, ...).
+	// ConnectionString and DirectURL are overwritten with the branch URLs.
+	Pool Config
+
+	// Timeout bounds branch creation, migration, and connection.
+	// Default: 2m.
+	Timeout time.Duration
+}
+
+// testBranchConnect is a package-private seam used by tests to avoid opening
+// real connections to the ephemeral branch.
+var testBranchConnect = Connect
+
+// NewTestBranch creates an ephemeral Neon branch for the calling test, runs
+// migrations against its direct URL, and returns a connected *Pool for its
+// pooled URL.
+//
+// Branch deletion is registered with t.Cleanup immediately after creation, so
+// the branch is removed even when setup fails or the test panics. The pool is
+// closed before the branch is deleted.
+//
+// In fake-API mode (cfg.LocalDSN set) no API calls are made and the returned
+// pool connects to LocalDSN instead.
+//
+// Any setup failure (create, URL lookup, Migrate, or connect) stops the test;
+// a failed branch deletion during cleanup is reported with t.Errorf.
+func NewTestBranch(t testing.TB, cfg TestBranchConfig, opts ...Option) *Pool {
+	t.Helper()
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTestBranchTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var urls BranchURLs
+	if cfg.LocalDSN != "" {
+		urls = BranchURLs{Pooled: cfg.LocalDSN, Direct: cfg.LocalDSN}
+	} else {
+		if cfg.API == nil {
+			t.Fatalf("neon: NewTestBranch requires TestBranchConfig.API or TestBranchConfig.LocalDSN")
+		}
+
+		name := testBranchName(t.Name())
+		branch, err := cfg.API.CreateBranch(ctx, name, cfg.ParentID)
+		if err != nil {
+			t.Fatalf("neon: create test branch %q failed: %s", name, redactDSNs(err.Error()))
+		}
+		if branch == nil || branch.ID == "" {
+			t.Fatalf("neon: create test branch %q returned no branch ID", name)
+		}
+
+		api, branchID := cfg.API, branch.ID
+		t.Cleanup(func() {
+			deleteCtx, cancelDelete := context.WithTimeout(context.Background(), testBranchDeleteTimeout)
+			defer cancelDelete()
+			if err := api.DeleteBranch(deleteCtx, branchID); err != nil {
+				t.Errorf("neon: delete test branch %s failed: %s", branchID, redactDSNs(err.Error()))
+			}
+		})
+
+		urls, err = cfg.API.BranchURLs(ctx, branch.ID)
+		if err != nil {
+			t.Fatalf("neon: resolve test branch %s URLs failed: %s", branch.ID, redactDSNs(err.Error()))
+		}
+		if urls.Pooled == "" || urls.Direct == "" {
+			t.Fatalf("neon: test branch %s is missing a pooled or direct URL", branch.ID)
+		}
+	}
+
+	if cfg.Migrate != nil {
+		if err := cfg.Migrate(ctx, urls.Direct); err != nil {
+			// SECURITY: migration runners are user code and may echo the DSN.
+			t.Fatalf("neon: test branch migration failed: %s", redactDSNs(err.Error()))
+		}
+	}
+
+	poolCfg := cfg.Pool
+	poolCfg.ConnectionString = urls.Pooled
+	poolCfg.DirectURL = urls.Direct
+
+	pool, err := testBranchConnect(ctx, poolCfg, opts...)
+	if err != nil {
+		t.Fatalf("neon: connect to test branch failed: %s", redactDSNs(err.Error()))
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+// testBranchName derives a Neon-safe branch name from a test name.
+func testBranchName(testName string) string {
+	return testResourceName(testName, '-', testBranchNameMaxLen)
+}
+
+// testResourceName derives a lowercase name from a test name for resources
+// the test helpers create. Runs of characters outside [a-z0-9] collapse to
+// sep, the sanitized name is cut to maxLen, and a random suffix is added so
+// parallel and repeated runs never collide. The result has the form
+// "test<sep><name><sep><suffix>".
+func testResourceName(testName string, sep byte, maxLen int) string {
+	var b strings.Builder
+	lastSep := false
+	for _, r := range strings.ToLower(testName) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			lastSep = false
+		default:
+			if !lastSep && b.Len() > 0 {
+				b.WriteByte(sep)
+				lastSep = true
+			}
+		}
+	}
+	trimSep := string(sep)
+	base := strings.TrimSuffix(b.String(), trimSep)
+	if len(base) > maxLen {
+		base = strings.TrimSuffix(base[:maxLen], trimSep)
+	}
+	if base == "" {
+		base = "test"
+	}
+
+	prefix := "test" + trimSep + base + trimSep
+	var suffix [4]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return fmt.Sprintf("%s%d", prefix, time.Now().UnixNano())
+	}
+	return prefix + hex.EncodeToString(suffix[:])
+}
@@ -0,0 +1,643 @@
+// Command vango-neon manages Neon branches through the Neon HTTP API.
+//
+// Usage:
+//
+//	vango-neon branch create <name> [--parent <name|id>] [--json]
+//	vango-neon branch list [--json]
+//	vango-neon branch delete <name|id> [--json]
+//	vango-neon branch reset <name|id> [--json]
+//	vango-neon connect [--branch <name|id>] [--env-file .env] [--json]
+//	vango-neon doctor [--env-file .env] [--max-conns N] [--instances N] [--json]
+//
+// Authentication uses NEON_API_KEY; the project comes from --project-id or
+// NEON_PROJECT_ID.
+//
+// Security: output (text and --json) never includes connection strings.
+// connect writes DATABASE_URL and DATABASE_URL_DIRECT to the env file
+// atomically with 0600 permissions and reports only host and database.
+// doctor runs the Appendix G.6.2 diagnostic checklist against DATABASE_URL
+// and DATABASE_URL_DIRECT and exits non-zero when any check fails.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	neon "github.com/vango-go/vango-neon"
+)
+
+// branchClient is the subset of *neon.APIClient used by the command.
+type branchClient interface {
+	CreateBranch(ctx context.Context, name, parentID string) (*neon.Branch, error)
+	ListBranches(ctx context.Context) ([]neon.Branch, error)
+	DeleteBranch(ctx context.Context, branchID string) error
+	ResetBranch(ctx context.Context, branchID, parentID string) error
+	BranchURLs(ctx context.Context, branchID string) (neon.BranchURLs, error)
+}
+
+type clientFactory func(neon.APIConfig) (branchClient, error)
+
+func newAPIClient(cfg neon.APIConfig) (branchClient, error) {
+	return neon.NewAPIClient(cfg)
+}
+
+// doctorFunc is a package-private seam used by tests to avoid live database
+// checks.
+var doctorFunc = neon.Doctor
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv, newAPIClient))
+}
+
+const usage = `usage:
+  vango-neon branch create <name> [--parent <name|id>] [--json]
+  vango-neon branch list [--json]
+  vango-neon branch delete <name|id> [--json]
+  vango-neon branch reset <name|id> [--json]
+  vango-neon connect [--branch <name|id>] [--env-file .env] [--json]
+  vango-neon doctor [--env-file .env] [--max-conns N] [--instances N] [--json]
+
+common flags:
+  --project-id  Neon project ID (default: $NEON_PROJECT_ID)
+  --database    database name for connection strings (default: neondb)
+  --role        role name for connection strings (default: neondb_owner)
+  --json        machine-readable output (never includes connection strings)
+`
+
+// errUsage marks errors that should be followed by the usage text.
+var errUsage = errors.New("invalid usage")
+
+type commonFlags struct {
+	jsonOut   bool
+	projectID string
+	database  string
+	role      string
+}
+
+type command struct {
+	out     io.Writer
+	errOut  io.Writer
+	getenv  func(string) string
+	factory clientFactory
+	flags   commonFlags
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string, factory clientFactory) int {
+	cmd := &command{out: stdout, errOut: stderr, getenv: getenv, factory: factory}
+
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "branch":
+		if len(args) < 2 {
+			err = fmt.Errorf("%w: missing branch subcommand", errUsage)
+			break
+		}
+		switch args[1] {
+		case "create":
+			err = cmd.branchCreate(ctx, args[2:])
+		case "list":
+			err = cmd.branchList(ctx, args[2:])
+		case "delete":
+			err = cmd.branchDelete(ctx, args[2:])
+		case "reset":
+			err = cmd.branchReset(ctx, args[2:])
+		default:
+			err = fmt.Errorf("%w: unknown branch subcommand %q", errUsage, args[1])
+		}
+	case "connect":
+		err = cmd.connect(ctx, args[1:])
+	case "doctor":
+		var failed bool
+		failed, err = cmd.doctor(ctx, args[1:])
+		if err == nil && failed {
+			return 1
+		}
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		err = fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+
+	if err == nil {
+		return 0
+	}
+	return cmd.fail(err)
+}
+
+func (c *command) fail(err error) int {
+	if c.flags.jsonOut {
+		_ = json.NewEncoder(c.out).Encode(map[string]string{"error": err.Error()})
+	} else {
+		fmt.Fprintf(c.errOut, "vango-neon: %s\n", err)
+	}
+	if errors.Is(err, errUsage) {
+		if !c.flags.jsonOut {
+			fmt.Fprint(c.errOut, usage)
+		}
+		return 2
+	}
+	return 1
+}
+
+func (c *command) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&c.flags.jsonOut, "json", false, "")
+	fs.StringVar(&c.flags.projectID, "project-id", "", "")
+	fs.StringVar(&c.flags.database, "database", "", "")
+	fs.StringVar(&c.flags.role, "role", "", "")
+	return fs
+}
+
+// parseArgs parses flags interspersed with positional arguments so that
+// "branch create feature/x --json" and "branch create --json feature/x" are
+// equivalent.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %s", errUsage, err)
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func (c *command) client() (branchClient, error) {
+	projectID := c.flags.projectID
+	if projectID == "" {
+		projectID = c.getenv("NEON_PROJECT_ID")
+	}
+	apiKey := c.getenv("NEON_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("NEON_API_KEY is not set")
+	}
+	if projectID == "" {
+		return nil, errors.New("project ID is required (--project-id or NEON_PROJECT_ID)")
+	}
+
+	return c.factory(neon.APIConfig{
+		APIKey:       apiKey,
+		ProjectID:    projectID,
+		DatabaseName: c.flags.database,
+		RoleName:     c.flags.role,
+	})
+}
+
+func (c *command) branchCreate(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("branch create")
+	parent := fs.String("parent", "", "")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return fmt.Errorf("%w: branch create requires exactly one <name>", errUsage)
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	parentID := ""
+	if *parent != "" {
+		branches, err := api.ListBranches(ctx)
+		if err != nil {
+			return err
+		}
+		p, err := findBranch(branches, *parent)
+		if err != nil {
+			return err
+		}
+		parentID = p.ID
+	}
+
+	b, err := api.CreateBranch(ctx, pos[0], parentID)
+	if err != nil {
+		return err
+	}
+
+	if c.flags.jsonOut {
+		return c.writeJSON(map[string]any{"created": b})
+	}
+	fmt.Fprintf(c.out, "created branch %s (id=%s%s)\n", b.Name, b.ID, hostSuffix(b.Host))
+	return nil
+}
+
+func (c *command) branchList(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("branch list")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return fmt.Errorf("%w: branch list takes no arguments", errUsage)
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	branches, err := api.ListBranches(ctx)
+	if err != nil {
+		return err
+	}
+
+	if c.flags.jsonOut {
+		if branches == nil {
+			branches = []neon.Branch{}
+		}
+		return c.writeJSON(map[string]any{"branches": branches})
+	}
+	for _, b := range branches {
+		fmt.Fprintf(c.out, "%s\t%s%s\n", b.ID, b.Name, hostSuffix(b.Host))
+	}
+	return nil
+}
+
+func (c *command) branchDelete(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("branch delete")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return fmt.Errorf("%w: branch delete requires exactly one <name|id>", errUsage)
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	b, err := c.resolveBranch(ctx, api, pos[0])
+	if err != nil {
+		return err
+	}
+	if err := api.DeleteBranch(ctx, b.ID); err != nil {
+		return err
+	}
+
+	if c.flags.jsonOut {
+		return c.writeJSON(map[string]any{"deleted": b})
+	}
+	fmt.Fprintf(c.out, "deleted branch %s (id=%s)\n", b.Name, b.ID)
+	return nil
+}
+
+func (c *command) branchReset(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("branch reset")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return fmt.Errorf("%w: branch reset requires exactly one <name|id>", errUsage)
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	b, err := c.resolveBranch(ctx, api, pos[0])
+	if err != nil {
+		return err
+	}
+	if b.ParentID == "" {
+		return fmt.Errorf("branch %s has no parent to reset from", b.Name)
+	}
+	if err := api.ResetBranch(ctx, b.ID, b.ParentID); err != nil {
+		return err
+	}
+
+	if c.flags.jsonOut {
+		return c.writeJSON(map[string]any{"reset": b})
+	}
+	fmt.Fprintf(c.out, "reset branch %s (id=%s) to parent %s\n", b.Name, b.ID, b.ParentID)
+	return nil
+}
+
+// connectSummary is the redacted result of connect. It never carries
+// credentials.
+type connectSummary struct {
+	Branch     string `json:"branch"`
+	BranchID   string `json:"branch_id"`
+	Host       string `json:"host"`
+	DirectHost string `json:"direct_host"`
+	Database   string `json:"database"`
+	EnvFile    string `json:"env_file"`
+}
+
+func (c *command) connect(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("connect")
+	branchRef := fs.String("branch", "main", "")
+	envFile := fs.String("env-file", ".env", "")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 0 {
+		return fmt.Errorf("%w: connect takes no positional arguments (use --branch)", errUsage)
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	b, err := c.resolveBranch(ctx, api, *branchRef)
+	if err != nil {
+		return err
+	}
+	urls, err := api.BranchURLs(ctx, b.ID)
+	if err != nil {
+		return err
+	}
+
+	pooledHost, database, err := summarizeURL(urls.Pooled)
+	if err != nil {
+		return err
+	}
+	directHost, _, err := summarizeURL(urls.Direct)
+	if err != nil {
+		return err
+	}
+
+	if err := writeEnvFile(*envFile, []envVar{
+		{key: "DATABASE_URL", value: urls.Pooled},
+		{key: "DATABASE_URL_DIRECT", value: urls.Direct},
+	}); err != nil {
+		return err
+	}
+
+	summary := connectSummary{
+		Branch:     b.Name,
+		BranchID:   b.ID,
+		Host:       pooledHost,
+		DirectHost: directHost,
+		Database:   database,
+		EnvFile:    *envFile,
+	}
+	if c.flags.jsonOut {
+		return c.writeJSON(summary)
+	}
+	fmt.Fprintf(c.out, "connected branch %s (id=%s)\n", summary.Branch, summary.BranchID)
+	fmt.Fprintf(c.out, "   Host: %s\n", summary.Host)
+	fmt.Fprintf(c.out, "   Database: %s\n", summary.Database)
+	fmt.Fprintf(c.out, "   Connection strings written to %s (not displayed for security)\n", summary.EnvFile)
+	return nil
+}
+
+// doctor writes the diagnostic report and reports whether any check failed.
+func (c *command) doctor(ctx context.Context, args []string) (failed bool, err error) {
+	fs := c.newFlagSet("doctor")
+	envFile := fs.String("env-file", "", "")
+	maxConns := fs.Int("max-conns", 0, "")
+	instances := fs.Int("instances", 0, "")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return false, err
+	}
+	if len(pos) != 0 {
+		return false, fmt.Errorf("%w: doctor takes no positional arguments", errUsage)
+	}
+
+	databaseURL := c.getenv("DATABASE_URL")
+	directURL := c.getenv("DATABASE_URL_DIRECT")
+	if *envFile != "" {
+		fileVars, err := readEnvFile(*envFile)
+		if err != nil {
+			return false, err
+		}
+		if databaseURL == "" {
+			databaseURL = fileVars["DATABASE_URL"]
+		}
+		if directURL == "" {
+			directURL = fileVars["DATABASE_URL_DIRECT"]
+		}
+	}
+
+	cfg := neon.DoctorConfig{
+		DatabaseURL: databaseURL,
+		DirectURL:   directURL,
+		MaxConns:    int32(*maxConns),
+		Instances:   *instances,
+	}
+	// Branch lookup is optional: only use the API when credentials exist.
+	if c.getenv("NEON_API_KEY") != "" && (c.flags.projectID != "" || c.getenv("NEON_PROJECT_ID") != "") {
+		api, err := c.client()
+		if err != nil {
+			return false, err
+		}
+		cfg.API = api
+	}
+
+	report := doctorFunc(ctx, cfg)
+	if c.flags.jsonOut {
+		err = report.WriteJSON(c.out)
+	} else {
+		err = report.WriteText(c.out)
+	}
+	if err != nil {
+		return false, err
+	}
+	return report.Status == neon.DoctorFail, nil
+}
+
+func (c *command) resolveBranch(ctx context.Context, api branchClient, ref string) (*neon.Branch, error) {
+	branches, err := api.ListBranches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return findBranch(branches, ref)
+}
+
+func (c *command) writeJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// findBranch matches ref against branch IDs first, then names.
+func findBranch(branches []neon.Branch, ref string) (*neon.Branch, error) {
+	for i := range branches {
+		if branches[i].ID == ref {
+			return &branches[i], nil
+		}
+	}
+	for i := range branches {
+		if branches[i].Name == ref {
+			return &branches[i], nil
+		}
+	}
+	return nil, fmt.Errorf("branch %q not found", ref)
+}
+
+func hostSuffix(host string) string {
+	if host == "" {
+		return ""
+	}
+	return ", host=" + host
+}
+
+// summarizeURL extracts the non-secret host and database name from a
+// connection string.
+func summarizeURL(dsn string) (host, database string, err error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Hostname() == "" {
+		// SECURITY: url.Parse errors echo the input.
+		return "", "", errors.New("neon api returned a connection string that is not URL-form")
+	}
+	return u.Hostname(), strings.TrimPrefix(u.Path, "/"), nil
+}
+
+type envVar struct {
+	key   string
+	value string
+}
+
+// writeEnvFile sets vars in the dotenv file at path, preserving unrelated
+// lines. The first line for a key is replaced in place and later duplicates
+// are removed, since the last assignment wins when the file is sourced. The
+// file is replaced atomically (temp file + rename) and always ends up with
+// 0600 permissions.
+func writeEnvFile(path string, vars []envVar) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(vars))
+	for _, v := range vars {
+		values[v.key] = v.value
+	}
+
+	var lines []string
+	written := make(map[string]bool, len(vars))
+	if len(existing) > 0 {
+		for _, line := range strings.Split(strings.TrimRight(string(existing), "\n"), "\n") {
+			key := envLineKey(line)
+			if value, ok := values[key]; ok {
+				if written[key] {
+					continue
+				}
+				line = formatEnvLine(key, value)
+				written[key] = true
+			}
+			lines = append(lines, line)
+		}
+	}
+	for _, v := range vars {
+		if !written[v.key] {
+			lines = append(lines, formatEnvLine(v.key, v.value))
+			written[v.key] = true
+		}
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".env.tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp env file in %s: %w", dir, err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("chmod temp env file: %w", err)
+	}
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp env file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temp env file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp env file: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// readEnvFile reads KEY=VALUE pairs from a dotenv file, unquoting values
+// written by writeEnvFile.
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	vars := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		key := envLineKey(line)
+		if key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		_, value, _ := strings.Cut(line, "=")
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = unescapeEnvValue(value[1 : len(value)-1])
+		} else {
+			value = strings.Trim(value, `'`)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+func envLineKey(line string) string {
+	trimmed := strings.TrimSpace(line)
+	trimmed = strings.TrimPrefix(trimmed, "export ")
+	key, _, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
+
+// envValueEscaper escapes the characters a shell (and dotenv loaders that
+// interpolate) treat specially inside double quotes. Neon passwords can
+// contain $ and backticks.
+var envValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`")
+
+func formatEnvLine(key, value string) string {
+	return key + `="` + envValueEscaper.Replace(value) + `"`
+}
+
+// unescapeEnvValue reverses envValueEscaper. As in a shell, a backslash
+// before any other character is kept.
+func unescapeEnvValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\\\"$`", s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,206 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func connectFakePG(t *testing.T, cfg Config) *Pool {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pool, err := Connect(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Connect error=%v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestFakePG_ConnectPingAndHealthOverTLS(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.Handle(`SELECT name FROM projects WHERE id = \$1`, 0, fakePGResult{
+		Columns: []fakePGColumn{{Name: "name", OID: pgtype.TextOID}},
+		Rows:    [][]any{{"Demo"}},
+	})
+	pool := connectFakePG(t, Config{ConnectionString: srv.URL("require"), ConnectTimeout: 2 * time.Second})
+
+	ctx := context.Background()
+	status, err := HealthCheck(ctx, pool)
+	if err != nil || status.Status != "ok" {
+		t.Fatalf("HealthCheck status=%+v error=%v", status, err)
+	}
+
+	var name string
+	if err := pool.QueryRow(ctx, "SELECT name FROM projects WHERE id = $1", "1").Scan(&name); err != nil {
+		t.Fatalf("QueryRow error=%v", err)
+	}
+	if name != "Demo" {
+		t.Fatalf("name=%q", name)
+	}
+
+	total, tlsConns := srv.Conns()
+	if total == 0 || total != tlsConns {
+		t.Fatalf("conns=%d tls=%d, want every connection over TLS", total, tlsConns)
+	}
+	queries := srv.Queries()
+	if len(queries) != 1 || !queries[0].Extended {
+		t.Fatalf("queries=%+v, want one extended-protocol query on a direct host", queries)
+	}
+}
+
+func TestFakePG_PlaintextRejectedBeforeDialing(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	_, err := Connect(context.Background(), Config{ConnectionString: srv.URL("disable")})
+	if err == nil || !strings.Contains(err.Error(), "insecure connection rejected") {
+		t.Fatalf("error=%v", err)
+	}
+	if total, _ := srv.Conns(); total != 0 {
+		t.Fatalf("conns=%d, want no dial for a plaintext configuration", total)
+	}
+}
+
+func TestFakePG_PoolerModeUsesSimpleProtocol(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.RejectExtendedProtocol()
+	srv.Handle(`SELECT count\(\*\) FROM projects WHERE owner = +'u''1'`, 0, fakePGResult{
+		Columns: []fakePGColumn{{Name: "count", OID: pgtype.Int8OID}},
+		Rows:    [][]any{{int64(7)}},
+	})
+	ctx := context.Background()
+
+	pooled := connectFakePG(t, Config{ConnectionString: srv.URL("require"), DirectURL: srv.URL("require"), ForcePoolerMode: true})
+	var count int64
+	if err := pooled.QueryRow(ctx, "SELECT count(*) FROM projects WHERE owner = $1", "u'1").Scan(&count); err != nil {
+		t.Fatalf("pooler-mode QueryRow error=%v", err)
+	}
+	if count != 7 {
+		t.Fatalf("count=%d", count)
+	}
+	for _, q := range srv.Queries() {
+		if q.Extended {
+			t.Fatalf("pooler mode sent an extended-protocol query: %+v", q)
+		}
+	}
+
+	// Without pooler mode the same query is prepared and the pooler rejects it.
+	direct := connectFakePG(t, Config{ConnectionString: srv.URL("require")})
+	err := direct.QueryRow(ctx, "SELECT count(*) FROM projects WHERE owner = $1", "u'1").Scan(&count)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "42P05" {
+		t.Fatalf("direct-mode error=%v, want 42P05", err)
+	}
+}
+
+func TestFakePG_ColdStartBeyondConnectTimeout(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.SetStartupDelay(500 * time.Millisecond)
+
+	_, err := Connect(context.Background(), Config{ConnectionString: srv.URL("require"), ConnectTimeout: 100 * time.Millisecond})
+	var safe *SafeError
+	if !errors.As(err, &safe) || !strings.Contains(err.Error(), "initial ping failed") {
+		t.Fatalf("error=%v, want initial ping SafeError", err)
+	}
+	if strings.Contains(err.Error(), "fakepass") {
+		t.Fatalf("error leaked credentials: %v", err)
+	}
+
+	start := time.Now()
+	connectFakePG(t, Config{ConnectionString: srv.URL("require"), ConnectTimeout: 3 * time.Second})
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Fatalf("connect took %v, want it to wait out the cold start", elapsed)
+	}
+}
+
+func TestFakePG_DroppedConnectionThenRetrySucceeds(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.DropNextConnections(1)
+	cfg := Config{ConnectionString: srv.URL("require"), ConnectTimeout: 2 * time.Second}
+
+	if _, err := Connect(context.Background(), cfg); err == nil {
+		t.Fatal("expected Connect to fail when the server drops the connection")
+	}
+	pool := connectFakePG(t, cfg)
+
+	srv.Handle(`^UPDATE projects`, 1, fakePGResult{Disconnect: true})
+	srv.Handle(`^UPDATE projects`, 0, fakePGResult{Tag: "UPDATE 1"})
+
+	ctx := context.Background()
+	var (
+		tag      pgconn.CommandTag
+		err      error
+		attempts int
+	)
+	for attempts = 1; attempts <= 3; attempts++ {
+		tag, err = pool.Exec(ctx, "UPDATE projects SET name = 'x'")
+		if err == nil {
+			break
+		}
+	}
+	if err != nil || tag.RowsAffected() != 1 {
+		t.Fatalf("Exec tag=%v error=%v", tag, err)
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts=%d, want success on the first retry", attempts)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		t.Fatalf("Ping after reconnect error=%v", err)
+	}
+}
+
+func TestFakePG_ScriptedErrorsAndTransactions(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.Handle(`^INSERT INTO projects`, 0, fakePGResult{ErrCode: "23505", ErrMessage: "duplicate key value violates unique constraint"})
+	srv.Handle(`^SELECT id, name, archived FROM projects`, 0, fakePGResult{
+		Columns: []fakePGColumn{{Name: "id", OID: pgtype.Int8OID}, {Name: "name", OID: pgtype.TextOID}, {Name: "archived", OID: pgtype.BoolOID}},
+		Rows:    [][]any{{int64(1), "Demo", false}, {int64(2), nil, true}},
+	})
+	pool := connectFakePG(t, Config{ConnectionString: srv.URL("require")})
+	ctx := context.Background()
+
+	err := WithTx(ctx, pool, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "INSERT INTO projects (name) VALUES ($1)", "Demo")
+		return err
+	})
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		t.Fatalf("WithTx error=%v", err)
+	}
+
+	type project struct {
+		ID       int64
+		Name     *string
+		Archived bool
+	}
+	rows, err := pool.Query(ctx, "SELECT id, name, archived FROM projects")
+	if err != nil {
+		t.Fatalf("Query error=%v", err)
+	}
+	got, err := pgx.CollectRows(rows, pgx.RowToStructByPos[project])
+	if err != nil {
+		t.Fatalf("CollectRows error=%v", err)
+	}
+	if len(got) != 2 || *got[0].Name != "Demo" || got[1].Name != nil || !got[1].Archived {
+		t.Fatalf("projects=%+v", got)
+	}
+}
@@ -0,0 +1,129 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestPool_CopyFromOverWire(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.Handle(`^select "name", "owner" from "projects"$`, 0, fakePGResult{
+		Columns: []fakePGColumn{{Name: "name", OID: pgtype.TextOID}, {Name: "owner", OID: pgtype.TextOID}},
+	})
+	srv.Handle(`^copy "projects"`, 1, fakePGResult{})
+	srv.Handle(`^copy "projects"`, 1, fakePGResult{ErrCode: "23505", ErrMessage: "duplicate key value violates unique constraint"})
+	srv.Handle(`^copy "projects"`, 0, fakePGResult{})
+	pool := connectFakePG(t, Config{ConnectionString: srv.URL("require")})
+	ctx := context.Background()
+	cols := []string{"name", "owner"}
+
+	// Rows are produced on demand rather than materialized up front.
+	i := 0
+	src := pgx.CopyFromFunc(func() ([]any, error) {
+		if i == 3 {
+			return nil, nil
+		}
+		i++
+		return []any{"project", "owner@example.com"}, nil
+	})
+	n, err := pool.CopyFrom(ctx, pgx.Identifier{"projects"}, cols, src)
+	if err != nil || n != 3 {
+		t.Fatalf("CopyFrom n=%d err=%v", n, err)
+	}
+
+	_, err = pool.CopyFrom(ctx, pgx.Identifier{"projects"}, cols, pgx.CopyFromRows([][]any{{"dup", "secret-owner@example.com"}}))
+	var safe *SafeError
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &safe) || !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		t.Fatalf("error=%v, want SafeError wrapping 23505", err)
+	}
+	if err.Error() != `neon: copy into "projects" failed` {
+		t.Fatalf("error=%q", err.Error())
+	}
+
+	srcErr := errors.New("read csv: line 2: secret-owner@example.com")
+	_, err = pool.CopyFrom(ctx, pgx.Identifier{"projects"}, cols, pgx.CopyFromFunc(func() ([]any, error) { return nil, srcErr }))
+	// pgx aborts the COPY with CopyFail carrying the source's message, so the
+	// server's error (not srcErr) is the cause.
+	if !errors.As(err, &pgErr) || pgErr.Code != "57014" || strings.Contains(err.Error(), "secret") {
+		t.Fatalf("source error=%v", err)
+	}
+
+	// The connection is still usable after a failed copy.
+	if err := pool.Ping(ctx); err != nil {
+		t.Fatalf("Ping after failed copy: %v", err)
+	}
+}
+
+func TestCopyRecorder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	rec := NewCopyRecorder()
+	db := &TestDB{CopyFromFunc: rec.CopyFrom}
+
+	// A source that reuses its values slice must not corrupt recorded rows.
+	buf := make([]any, 2)
+	names := []string{"a", "b"}
+	i := 0
+	src := pgx.CopyFromFunc(func() ([]any, error) {
+		if i == len(names) {
+			return nil, nil
+		}
+		buf[0], buf[1] = names[i], int64(i)
+		i++
+		return buf, nil
+	})
+	if n, err := db.CopyFrom(ctx, pgx.Identifier{"public", "projects"}, []string{"name", "rank"}, src); err != nil || n != 2 {
+		t.Fatalf("CopyFrom n=%d err=%v", n, err)
+	}
+	if _, err := db.CopyFrom(ctx, pgx.Identifier{"projects"}, []string{"name"}, pgx.CopyFromRows([][]any{{"c"}})); err != nil {
+		t.Fatalf("CopyFrom err=%v", err)
+	}
+
+	rec.AssertRowCount(t, "public.projects", 2)
+	rec.AssertRowCount(t, "projects", 1)
+	if rows := rec.Rows("public.projects"); rows[0][0] != "a" || rows[1][0] != "b" || rows[1][1] != int64(1) {
+		t.Fatalf("rows=%v", rows)
+	}
+	if copies := rec.Copies(); len(copies) != 2 || copies[0].ColumnNames[1] != "rank" {
+		t.Fatalf("copies=%+v", copies)
+	}
+	db.AssertCallCount(t, "CopyFrom", 2)
+
+	rt := &recordingTB{}
+	rec.AssertRowCount(rt, "projects", 5)
+	if len(rt.errs) != 1 || !strings.Contains(rt.errs[0], "1 row(s) copied into projects, want 5") {
+		t.Fatalf("AssertRowCount errors=%v", rt.errs)
+	}
+
+	if _, err := rec.CopyFrom(ctx, pgx.Identifier{"t"}, []string{"a", "b"}, pgx.CopyFromRows([][]any{{1}})); err == nil || !strings.Contains(err.Error(), "row 0 has 1 values, want 2") {
+		t.Fatalf("width err=%v", err)
+	}
+	srcErr := errors.New("bad input")
+	if _, err := rec.CopyFrom(ctx, pgx.Identifier{"t"}, []string{"a"}, pgx.CopyFromFunc(func() ([]any, error) { return nil, srcErr })); !errors.Is(err, srcErr) {
+		t.Fatalf("source err=%v", err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := rec.CopyFrom(canceled, pgx.Identifier{"t"}, []string{"a"}, pgx.CopyFromRows([][]any{{1}})); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled err=%v", err)
+	}
+
+	boom := errors.New("boom")
+	rec.WithError(boom)
+	if _, err := db.CopyFrom(ctx, pgx.Identifier{"t"}, []string{"a"}, pgx.CopyFromRows([][]any{{1}})); !errors.Is(err, boom) {
+		t.Fatalf("WithError err=%v", err)
+	}
+	if got := len(rec.Copies()); got != 2 {
+		t.Fatalf("copies=%d, want failed copies unrecorded", got)
+	}
+}
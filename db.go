@@ -2,6 +2,7 @@ package neon
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -48,3 +49,40 @@ type DB interface {
 	// Close releases all pool resources. Call once during graceful shutdown.
 	Close()
 }
+
+// DBTX is the query surface sqlc generates for pgx/v5 (emit_methods_with_db_argument
+// or New(db DBTX)). *Pool, *TestDB, and any pgx.Tx satisfy it, so generated
+// queries run unchanged against the pool, inside WithTx, and in unit tests.
+// Use AsDBTX to adapt other DB implementations.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+var _ DBTX = (pgx.Tx)(nil)
+
+// AsDBTX returns db as a DBTX. Implementations that already provide CopyFrom
+// and SendBatch are returned as is; for others (such as HTTPDB or MockDB) the
+// adapter forwards Exec, Query, and QueryRow, and CopyFrom and SendBatch
+// return an error.
+func AsDBTX(db DB) DBTX {
+	if d, ok := db.(DBTX); ok {
+		return d
+	}
+	return dbtxAdapter{db}
+}
+
+type dbtxAdapter struct {
+	DB
+}
+
+func (a dbtxAdapter) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return 0, fmt.Errorf("neon: %T does not support CopyFrom", a.DB)
+}
+
+func (a dbtxAdapter) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &errBatchResults{err: fmt.Errorf("neon: %T does not support SendBatch", a.DB)}
+}
@@ -0,0 +1,158 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// sqlcQueries mirrors the shape of sqlc-generated code for pgx/v5.
+type sqlcQueries struct {
+	db DBTX
+}
+
+func (q *sqlcQueries) CountProjects(ctx context.Context) (int64, error) {
+	var n int64
+	err := q.db.QueryRow(ctx, "-- name: CountProjects :one\nSELECT count(*) FROM projects").Scan(&n)
+	return n, err
+}
+
+func (q *sqlcQueries) ImportProjects(ctx context.Context, names []string) (int64, error) {
+	rows := make([][]any, len(names))
+	for i, n := range names {
+		rows[i] = []any{n}
+	}
+	return q.db.CopyFrom(ctx, pgx.Identifier{"projects"}, []string{"name"}, pgx.CopyFromRows(rows))
+}
+
+func (q *sqlcQueries) ArchiveProjects(ctx context.Context, ids []int64) error {
+	b := &pgx.Batch{}
+	for _, id := range ids {
+		b.Queue("UPDATE projects SET archived = true WHERE id = $1", id)
+	}
+	return q.db.SendBatch(ctx, b).Close()
+}
+
+func TestDBTX_SqlcQueriesRunAgainstTestDBAndFakeTx(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	var copied [][]any
+	db := &TestDB{
+		QueryRowFunc: func(context.Context, string, ...any) pgx.Row { return NewRow(int64(2)) },
+		CopyFromFunc: func(_ context.Context, table pgx.Identifier, cols []string, src pgx.CopyFromSource) (int64, error) {
+			for src.Next() {
+				values, err := src.Values()
+				if err != nil {
+					return 0, err
+				}
+				copied = append(copied, values)
+			}
+			return int64(len(copied)), src.Err()
+		},
+		SendBatchFunc: func(context.Context, *pgx.Batch) pgx.BatchResults {
+			return &errBatchResults{}
+		},
+	}
+
+	q := &sqlcQueries{db: db}
+	if n, err := q.CountProjects(ctx); err != nil || n != 2 {
+		t.Fatalf("CountProjects n=%d err=%v", n, err)
+	}
+	if n, err := q.ImportProjects(ctx, []string{"a", "b"}); err != nil || n != 2 || copied[1][0] != "b" {
+		t.Fatalf("ImportProjects n=%d err=%v copied=%v", n, err, copied)
+	}
+
+	tx := &FakeTx{DB: db}
+	err := WithTx(ctx, &TestDB{BeginTxFunc: func(context.Context, pgx.TxOptions) (pgx.Tx, error) { return tx, nil }}, pgx.TxOptions{},
+		func(tx pgx.Tx) error {
+			return (&sqlcQueries{db: tx}).ArchiveProjects(ctx, []int64{1, 2})
+		})
+	if err != nil || !tx.Committed() {
+		t.Fatalf("WithTx err=%v committed=%v", err, tx.Committed())
+	}
+
+	calls := db.Calls()
+	last := calls[len(calls)-1]
+	if last.Method != "SendBatch" || !strings.Contains(last.SQL, "UPDATE projects SET archived") {
+		t.Fatalf("last call=%+v", last)
+	}
+	if calls[1].Method != "CopyFrom" || calls[1].SQL != `"projects"` {
+		t.Fatalf("copy call=%+v", calls[1])
+	}
+}
+
+func TestDBTX_UnmockedAndUnsupported(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &TestDB{}
+	if _, err := db.CopyFrom(ctx, pgx.Identifier{"t"}, []string{"a"}, pgx.CopyFromRows(nil)); !errors.Is(err, ErrNotMocked) {
+		t.Fatalf("CopyFrom err=%v", err)
+	}
+	br := db.SendBatch(ctx, &pgx.Batch{})
+	if _, err := br.Exec(); !errors.Is(err, ErrNotMocked) {
+		t.Fatalf("SendBatch Exec err=%v", err)
+	}
+	if err := br.Close(); !errors.Is(err, ErrNotMocked) {
+		t.Fatalf("SendBatch Close err=%v", err)
+	}
+	db.AssertCallCount(t, "CopyFrom", 1)
+	db.AssertCallCount(t, "SendBatch", 1)
+
+	if AsDBTX(db) != DBTX(db) {
+		t.Fatal("AsDBTX should return a DBTX implementation unchanged")
+	}
+
+	mock := NewMockDB()
+	mock.ExpectExec("DELETE").WillReturnResult(pgconn.NewCommandTag("DELETE 1"))
+	adapted := AsDBTX(mock)
+	if tag, err := adapted.Exec(ctx, "DELETE FROM t"); err != nil || tag.RowsAffected() != 1 {
+		t.Fatalf("adapted Exec tag=%v err=%v", tag, err)
+	}
+	if _, err := adapted.CopyFrom(ctx, pgx.Identifier{"t"}, nil, pgx.CopyFromRows(nil)); err == nil || !strings.Contains(err.Error(), "*neon.MockDB does not support CopyFrom") {
+		t.Fatalf("adapted CopyFrom err=%v", err)
+	}
+	if err := adapted.SendBatch(ctx, &pgx.Batch{}).Close(); err == nil || !strings.Contains(err.Error(), "SendBatch") {
+		t.Fatalf("adapted SendBatch err=%v", err)
+	}
+
+	closed := &FakeTx{DB: db}
+	_ = closed.Rollback(ctx)
+	if _, err := closed.CopyFrom(ctx, pgx.Identifier{"t"}, nil, pgx.CopyFromRows(nil)); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("closed CopyFrom err=%v", err)
+	}
+}
+
+func TestPool_SendBatchOverWire(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.Handle(`^SELECT name FROM projects WHERE id = \$1`, 0, fakePGResult{
+		Columns: []fakePGColumn{{Name: "name", OID: pgtype.TextOID}},
+		Rows:    [][]any{{"Demo"}},
+	})
+	srv.Handle(`^UPDATE projects`, 0, fakePGResult{Tag: "UPDATE 1"})
+	pool := connectFakePG(t, Config{ConnectionString: srv.URL("require")})
+
+	b := &pgx.Batch{}
+	b.Queue("UPDATE projects SET archived = true WHERE id = $1", "1")
+	b.Queue("SELECT name FROM projects WHERE id = $1", "1")
+	br := AsDBTX(pool).SendBatch(context.Background(), b)
+	tag, err := br.Exec()
+	if err != nil || tag.RowsAffected() != 1 {
+		t.Fatalf("batch Exec tag=%v err=%v", tag, err)
+	}
+	var name string
+	if err := br.QueryRow().Scan(&name); err != nil || name != "Demo" {
+		t.Fatalf("batch QueryRow name=%q err=%v", name, err)
+	}
+	if err := br.Close(); err != nil {
+		t.Fatalf("Close err=%v", err)
+	}
+}
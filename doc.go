@@ -15,11 +15,31 @@
 //
 // Core API:
 //   - DB: application-facing data access contract
+//   - DBTX and AsDBTX: sqlc-compatible surface (adds CopyFrom and SendBatch)
+//   - BatchDB and WithBatch: pooler-safe pgx batches, also inside WithTx
+//   - Pool.CopyFrom: streaming COPY bulk loads with SafeError-wrapped failures
 //   - Config + Connect: Neon-oriented connection and pool setup
+//   - WithWebSocket: tunnel connections over wss:// for networks that block 5432
 //   - Pool: concrete DB implementation with Stat() and DirectURL()
+//   - Pool.TryLock, Lock, WithLock: advisory locks on a dedicated direct session
+//   - LeaderElector: single-leader election over a session advisory lock
+//   - database/sql: Pool.SQLDB, OpenSQLDB, OpenDirectSQLDB (same invariants)
+//   - HTTPDB: DB over Neon's SQL-over-HTTPS endpoint for short-lived processes
 //   - SafeError: safe outer error wrapper for production logging defaults
 //   - HealthCheck and WithTx: helper functions over the DB interface
-//   - Test kit: TestDB, ErrRow, ErrRows, NewRow, RowsBuilder
+//   - QueryOne, QueryAll, QueryMaybe, Exists: generic typed queries over a Querier
+//   - Paginator and Paginate: keyset pagination with signed cursor tokens
+//   - Test kit: TestDB, ErrRow, ErrRows, NewRow, RowsBuilder, FakeTx,
+//     NewBatchResults, CopyRecorder
+//   - Expectation mock: MockDB (sqlmock-style ordered/unordered expectations)
+//   - Fault injection: FaultDB decorator (latency, errors, cancellation races)
+//   - Golden-file tests: ReplayDB (NewRecordingDB, LoadReplayDB)
+//   - Branch-per-test harness: NewTestBranch over a BranchAPI
+//   - Transactional tests: NewTestTx (rolled back in t.Cleanup)
+//   - Schema isolation: NewTestSchema (pinned search_path, dropped in t.Cleanup)
+//   - Branch detection: CurrentGitBranch, NeonBranchName, DetectBranch
+//   - Neon API: APIClient (branch create/list/delete/reset, connection URLs)
+//   - Diagnostics: Doctor and DoctorReport
 //
 // Invariants:
 //   - I1: database I/O belongs in Resource loaders and Action work functions.
@@ -32,4 +52,6 @@
 //   - Connection strings and Pool.DirectURL() contain credentials.
 //   - SafeError.Unwrap() may include sensitive upstream detail.
 //   - Production logs should prefer safe outer error strings and metadata.
+//   - Test helpers (NewTestBranch, NewTestTx, NewTestSchema) report failures
+//     through testing.TB with connection strings redacted.
 package neon
@@ -0,0 +1,402 @@
+package neon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultDoctorColdStartThreshold = 500 * time.Millisecond
+	defaultDoctorTimeout            = 30 * time.Second
+	doctorMinServerVersionNum       = 140000
+	doctorPoolUtilizationWarn       = 0.8
+)
+
+// DoctorStatus is the outcome of a single diagnostic check.
+type DoctorStatus string
+
+const (
+	DoctorPass DoctorStatus = "pass"
+	DoctorWarn DoctorStatus = "warn"
+	DoctorFail DoctorStatus = "fail"
+	DoctorSkip DoctorStatus = "skip"
+)
+
+// DoctorCheck is one line of a DoctorReport. Detail never contains
+// credentials.
+type DoctorCheck struct {
+	Name   string       `json:"name"`
+	Status DoctorStatus `json:"status"`
+	Detail string       `json:"detail"`
+}
+
+// DoctorReport is the result of Doctor.
+type DoctorReport struct {
+	// Status is the worst status across all checks (fail > warn > pass).
+	Status DoctorStatus  `json:"status"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+// DoctorConfig configures Doctor.
+type DoctorConfig struct {
+	// DatabaseURL is the application (typically pooled) URL, DATABASE_URL.
+	DatabaseURL string
+
+	// DirectURL is the direct URL, DATABASE_URL_DIRECT.
+	DirectURL string
+
+	// MaxConns and Instances describe the planned deployment and are compared
+	// against the server's max_connections when DatabaseURL is a direct
+	// endpoint. Defaults: 10 and 1.
+	MaxConns  int32
+	Instances int
+
+	// API optionally resolves which Neon branch DatabaseURL points at.
+	API BranchLister
+
+	// ColdStartThreshold is the connect latency above which a slow first
+	// connection is reported as a probable cold start. Default: 500ms.
+	ColdStartThreshold time.Duration
+
+	// Timeout bounds all live checks. Default: 30s.
+	Timeout time.Duration
+}
+
+// doctorConnect is a package-private seam used by tests to run live checks
+// against a TestDB instead of a real connection.
+var doctorConnect = func(ctx context.Context, cfg Config) (DB, error) {
+	return Connect(ctx, cfg)
+}
+
+// Doctor runs the diagnostic checklist (Appendix G.6.2) and returns a
+// pass/warn/fail report.
+//
+// Static checks cover environment presence, TLS posture, channel binding,
+// pooler/direct classification, and direct URL derivation consistency. When
+// the static checks pass, live checks measure connect latency (with cold-start
+// detection), server version, current branch, migration status, and pool
+// sizing against max_connections. Pool sizing is skipped for pooled endpoints,
+// where client connections do not map to server backends.
+func Doctor(ctx context.Context, cfg DoctorConfig) *DoctorReport {
+	r := &DoctorReport{}
+
+	databaseURL := strings.TrimSpace(cfg.DatabaseURL)
+	directURL := strings.TrimSpace(cfg.DirectURL)
+
+	if databaseURL == "" {
+		r.add("env.database_url", DoctorFail, "DATABASE_URL is not set")
+	} else {
+		r.add("env.database_url", DoctorPass, "DATABASE_URL is set")
+	}
+	if directURL == "" {
+		r.add("env.database_url_direct", DoctorWarn, "DATABASE_URL_DIRECT is not set; migrations rely on derivation from DATABASE_URL")
+	} else {
+		r.add("env.database_url_direct", DoctorPass, "DATABASE_URL_DIRECT is set")
+	}
+	if databaseURL == "" {
+		r.finish()
+		return r
+	}
+
+	appCfg, ok := r.checkTLS("tls.database_url", "DATABASE_URL", databaseURL)
+	var directCfg *pgx.ConnConfig
+	if directURL != "" {
+		var directOK bool
+		directCfg, directOK = r.checkTLS("tls.database_url_direct", "DATABASE_URL_DIRECT", directURL)
+		ok = ok && directOK
+	}
+	if !ok {
+		r.finish()
+		return r
+	}
+
+	r.checkChannelBinding(appCfg, directCfg)
+	r.checkClassification(appCfg, directCfg)
+	if !r.checkDirectConsistency(databaseURL, directURL, appCfg, directCfg) {
+		r.finish()
+		return r
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultDoctorTimeout
+	}
+	liveCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	r.runLiveChecks(liveCtx, cfg, databaseURL, directURL, appCfg.Host)
+
+	r.finish()
+	return r
+}
+
+func (r *DoctorReport) add(name string, status DoctorStatus, detail string) {
+	r.Checks = append(r.Checks, DoctorCheck{Name: name, Status: status, Detail: detail})
+}
+
+func (r *DoctorReport) finish() {
+	r.Status = DoctorPass
+	for _, c := range r.Checks {
+		switch {
+		case c.Status == DoctorFail:
+			r.Status = DoctorFail
+			return
+		case c.Status == DoctorWarn:
+			r.Status = DoctorWarn
+		}
+	}
+}
+
+func (r *DoctorReport) checkTLS(name, source, dsn string) (*pgx.ConnConfig, bool) {
+	connCfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		// SECURITY: parse errors from upstream may contain DSN content.
+		r.add(name, DoctorFail, source+" is not a valid connection string")
+		return nil, false
+	}
+	if err := validateTLSPosture(source, connCfg); err != nil {
+		r.add(name, DoctorFail, err.Error())
+		return nil, false
+	}
+	r.add(name, DoctorPass, source+" requires TLS (host="+connCfg.Host+")")
+	return connCfg, true
+}
+
+func (r *DoctorReport) checkChannelBinding(appCfg, directCfg *pgx.ConnConfig) {
+	var missing []string
+	if appCfg.RuntimeParams["channel_binding"] != "require" {
+		missing = append(missing, "DATABASE_URL")
+	}
+	if directCfg != nil && directCfg.RuntimeParams["channel_binding"] != "require" {
+		missing = append(missing, "DATABASE_URL_DIRECT")
+	}
+	if len(missing) > 0 {
+		r.add("tls.channel_binding", DoctorWarn,
+			"channel_binding=require not set on "+strings.Join(missing, ", ")+" (recommended hardening)")
+		return
+	}
+	r.add("tls.channel_binding", DoctorPass, "channel_binding=require is set")
+}
+
+func (r *DoctorReport) checkClassification(appCfg, directCfg *pgx.ConnConfig) {
+	appPooler := isNeonPoolerHost(appCfg.Host)
+	switch {
+	case appPooler:
+		r.add("endpoint.database_url", DoctorPass, "DATABASE_URL is a Neon pooled endpoint (simple protocol will be used)")
+	case strings.HasSuffix(appCfg.Host, ".neon.tech"):
+		r.add("endpoint.database_url", DoctorWarn, "DATABASE_URL is a direct Neon endpoint; the pooled endpoint is recommended for application traffic")
+	default:
+		r.add("endpoint.database_url", DoctorWarn, "DATABASE_URL is not a Neon hostname; set ForcePoolerMode if it is behind a transaction pooler")
+	}
+
+	if directCfg == nil {
+		return
+	}
+	if isNeonPoolerHost(directCfg.Host) {
+		r.add("endpoint.database_url_direct", DoctorFail, `DATABASE_URL_DIRECT is a pooled endpoint (hostname must not include "-pooler")`)
+		return
+	}
+	r.add("endpoint.database_url_direct", DoctorPass, "DATABASE_URL_DIRECT is a direct endpoint")
+}
+
+func (r *DoctorReport) checkDirectConsistency(databaseURL, directURL string, appCfg, directCfg *pgx.ConnConfig) bool {
+	resolved, err := resolveDirectURL(Config{ConnectionString: databaseURL, DirectURL: directURL}, appCfg.Host)
+	if err != nil {
+		r.add("direct_url.consistency", DoctorFail, err.Error())
+		return false
+	}
+	if err := validateResolvedDirectURL(resolved); err != nil {
+		r.add("direct_url.consistency", DoctorFail, err.Error())
+		return false
+	}
+
+	if directCfg == nil {
+		if resolved == databaseURL {
+			r.add("direct_url.consistency", DoctorWarn, "no direct URL available; DATABASE_URL is used for migrations")
+		} else {
+			r.add("direct_url.consistency", DoctorPass, "direct URL derived from DATABASE_URL")
+		}
+		return true
+	}
+
+	if !isNeonPoolerHost(appCfg.Host) && !strings.HasSuffix(appCfg.Host, ".neon.tech") {
+		r.add("direct_url.consistency", DoctorSkip, "DATABASE_URL is not a Neon hostname; derivation not applicable")
+		return true
+	}
+	if !strings.EqualFold(directHostname(appCfg.Host), directCfg.Host) {
+		r.add("direct_url.consistency", DoctorFail, fmt.Sprintf(
+			"DATABASE_URL_DIRECT host (%s) does not match the endpoint of DATABASE_URL (%s); the URLs may point at different branches",
+			directCfg.Host, directHostname(appCfg.Host)))
+		return true
+	}
+	if appCfg.Database != directCfg.Database || appCfg.User != directCfg.User {
+		r.add("direct_url.consistency", DoctorWarn, "DATABASE_URL and DATABASE_URL_DIRECT use different databases or roles")
+		return true
+	}
+	r.add("direct_url.consistency", DoctorPass, "DATABASE_URL_DIRECT matches the endpoint of DATABASE_URL")
+	return true
+}
+
+func (r *DoctorReport) runLiveChecks(ctx context.Context, cfg DoctorConfig, databaseURL, directURL, host string) {
+	threshold := cfg.ColdStartThreshold
+	if threshold <= 0 {
+		threshold = defaultDoctorColdStartThreshold
+	}
+
+	start := time.Now()
+	db, err := doctorConnect(ctx, Config{
+		ConnectionString: databaseURL,
+		DirectURL:        directURL,
+		MaxConns:         1,
+	})
+	connectLatency := time.Since(start)
+	if err != nil {
+		r.add("connect.latency", DoctorFail, redactDSNs(err.Error()))
+		return
+	}
+	defer db.Close()
+
+	start = time.Now()
+	if err := db.Ping(ctx); err != nil {
+		r.add("connect.latency", DoctorFail, "ping after connect failed: "+redactDSNs(err.Error()))
+		return
+	}
+	pingLatency := time.Since(start)
+
+	latencyDetail := fmt.Sprintf("connect=%s ping=%s", connectLatency.Round(time.Millisecond), pingLatency.Round(time.Millisecond))
+	if connectLatency > threshold && connectLatency > 4*pingLatency {
+		r.add("connect.latency", DoctorWarn, latencyDetail+" (slow first connection: probable cold start; consider MinConns > 0 or a longer auto-suspend delay)")
+	} else {
+		r.add("connect.latency", DoctorPass, latencyDetail)
+	}
+
+	var versionNum, maxConnections int
+	var version, database string
+	err = db.QueryRow(ctx,
+		"SELECT current_setting('server_version_num')::int, current_setting('server_version'), "+
+			"current_setting('max_connections')::int, current_database()",
+	).Scan(&versionNum, &version, &maxConnections, &database)
+	if err != nil {
+		r.add("server.version", DoctorFail, "server settings query failed: "+redactDSNs(err.Error()))
+		return
+	}
+	if versionNum < doctorMinServerVersionNum {
+		r.add("server.version", DoctorWarn, fmt.Sprintf("PostgreSQL %s is older than 14", version))
+	} else {
+		r.add("server.version", DoctorPass, fmt.Sprintf("PostgreSQL %s (database=%s)", version, database))
+	}
+
+	r.checkBranch(ctx, cfg.API, host)
+	r.checkMigrations(ctx, db)
+	r.checkPoolSizing(cfg, maxConnections, isNeonPoolerHost(host))
+}
+
+func (r *DoctorReport) checkBranch(ctx context.Context, api BranchLister, host string) {
+	if api == nil {
+		r.add("neon.branch", DoctorSkip, "Neon API not configured; current branch unknown (host="+host+")")
+		return
+	}
+	branches, err := api.ListBranches(ctx)
+	if err != nil {
+		r.add("neon.branch", DoctorWarn, "list branches failed: "+redactDSNs(err.Error()))
+		return
+	}
+	want := directHostname(host)
+	for _, b := range branches {
+		if b.Host != "" && strings.EqualFold(directHostname(b.Host), want) {
+			r.add("neon.branch", DoctorPass, fmt.Sprintf("connected to branch %s (id=%s)", b.Name, b.ID))
+			return
+		}
+	}
+	r.add("neon.branch", DoctorWarn, "host "+host+" does not belong to any branch of the configured project")
+}
+
+func (r *DoctorReport) checkMigrations(ctx context.Context, db DB) {
+	var hasGoose, hasMigrate bool
+	err := db.QueryRow(ctx,
+		"SELECT to_regclass('goose_db_version') IS NOT NULL, to_regclass('schema_migrations') IS NOT NULL",
+	).Scan(&hasGoose, &hasMigrate)
+	if err != nil {
+		r.add("migrations", DoctorWarn, "migration table lookup failed: "+redactDSNs(err.Error()))
+		return
+	}
+
+	switch {
+	case hasGoose:
+		var version int64
+		err := db.QueryRow(ctx, "SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied").Scan(&version)
+		if err != nil {
+			r.add("migrations", DoctorWarn, "goose version query failed: "+redactDSNs(err.Error()))
+			return
+		}
+		r.add("migrations", DoctorPass, fmt.Sprintf("goose version %d", version))
+	case hasMigrate:
+		var version int64
+		var dirty bool
+		err := db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+		if err != nil {
+			r.add("migrations", DoctorWarn, "schema_migrations query failed: "+redactDSNs(err.Error()))
+			return
+		}
+		if dirty {
+			r.add("migrations", DoctorFail, fmt.Sprintf("schema_migrations version %d is dirty (a migration failed part-way)", version))
+			return
+		}
+		r.add("migrations", DoctorPass, fmt.Sprintf("schema_migrations version %d", version))
+	default:
+		r.add("migrations", DoctorWarn, "no goose_db_version or schema_migrations table found; migrations may not have run on this branch")
+	}
+}
+
+func (r *DoctorReport) checkPoolSizing(cfg DoctorConfig, maxConnections int, pooled bool) {
+	maxConns := int(cfg.MaxConns)
+	if maxConns <= 0 {
+		maxConns = 10
+	}
+	instances := cfg.Instances
+	if instances <= 0 {
+		instances = 1
+	}
+	total := maxConns * instances
+	detail := fmt.Sprintf("MaxConns=%d x instances=%d = %d of max_connections=%d", maxConns, instances, total, maxConnections)
+
+	switch {
+	case pooled:
+		// The pooler multiplexes client connections onto a small set of
+		// backends, so the client total is not bounded by max_connections.
+		r.add("pool.sizing", DoctorSkip, fmt.Sprintf(
+			"DATABASE_URL is a pooled endpoint; MaxConns=%d x instances=%d = %d client connections are multiplexed by the pooler and not limited by max_connections=%d",
+			maxConns, instances, total, maxConnections))
+	case maxConnections <= 0:
+		r.add("pool.sizing", DoctorSkip, "max_connections unavailable")
+	case total > maxConnections:
+		r.add("pool.sizing", DoctorFail, detail+" (exceeds server limit)")
+	case float64(total) > doctorPoolUtilizationWarn*float64(maxConnections):
+		r.add("pool.sizing", DoctorWarn, detail+" (above 80% of server limit)")
+	default:
+		r.add("pool.sizing", DoctorPass, detail)
+	}
+}
+
+// WriteText writes a human-readable report to w.
+func (r *DoctorReport) WriteText(w io.Writer) error {
+	for _, c := range r.Checks {
+		if _, err := fmt.Fprintf(w, "[%s] %s: %s\n", strings.ToUpper(string(c.Status)), c.Name, c.Detail); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "overall: %s\n", r.Status)
+	return err
+}
+
+// WriteJSON writes the report as indented JSON to w.
+func (r *DoctorReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -1,5 +1,7 @@
 package neon
 
+import "regexp"
+
 // SafeError wraps a cause with an error string safe for default production
 // logging. The wrapped cause may still contain sensitive detail.
 type SafeError struct {
@@ -9,3 +11,16 @@ type SafeError struct {
 
 func (e *SafeError) Error() string { return e.msg }
 func (e *SafeError) Unwrap() error { return e.cause }
+
+var (
+	redactDSNURLPattern   = regexp.MustCompile(`(?i)postgres(?:ql)?://[^\s"']+`)
+	redactPasswordPattern = regexp.MustCompile(`(?i)password=[^\s"']+`)
+)
+
+// redactDSNs masks URL-form DSNs and keyword/value passwords in s. It is used
+// when surfacing messages that originate outside this package (for example,
+// user-supplied migration runners) where DSN content cannot be ruled out.
+func redactDSNs(s string) string {
+	s = redactDSNURLPattern.ReplaceAllString(s, "[REDACTED_DSN]")
+	return redactPasswordPattern.ReplaceAllString(s, "password=[REDACTED]")
+}
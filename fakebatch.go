@@ -0,0 +1,190 @@
+package neon
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// BatchResultsBuilder builds fake pgx.BatchResults for TestDB.SendBatchFunc.
+// Add one result per queued query, in order:
+//
+//	db.SendBatchFunc = func(_ context.Context, b *pgx.Batch) pgx.BatchResults {
+//		return neon.NewBatchResults().
+//			AddExec(pgconn.NewCommandTag("UPDATE 1")).
+//			AddRows(neon.NewRows([]string{"id"}).AddRow(int64(7))).
+//			BuildFor(b)
+//	}
+//
+// Each result is independent: an error added with AddError is returned by the
+// read that consumes it and by Close, and later results are still readable.
+type BatchResultsBuilder struct {
+	results  []fakeBatchResult
+	closeErr error
+}
+
+type fakeBatchResult struct {
+	tag  pgconn.CommandTag
+	rows *RowsBuilder
+	err  error
+}
+
+// NewBatchResults creates an empty BatchResultsBuilder.
+func NewBatchResults() *BatchResultsBuilder {
+	return &BatchResultsBuilder{}
+}
+
+// AddExec appends a result that reports tag.
+func (b *BatchResultsBuilder) AddExec(tag pgconn.CommandTag) *BatchResultsBuilder {
+	b.results = append(b.results, fakeBatchResult{tag: tag})
+	return b
+}
+
+// AddRows appends a result read with Query or QueryRow. Read with Exec, it
+// reports the rows' command tag.
+func (b *BatchResultsBuilder) AddRows(rows *RowsBuilder) *BatchResultsBuilder {
+	b.results = append(b.results, fakeBatchResult{rows: rows})
+	return b
+}
+
+// AddError appends a result that fails with err.
+func (b *BatchResultsBuilder) AddError(err error) *BatchResultsBuilder {
+	b.results = append(b.results, fakeBatchResult{err: err})
+	return b
+}
+
+// WithCloseError makes Close return err when no result failed first.
+func (b *BatchResultsBuilder) WithCloseError(err error) *BatchResultsBuilder {
+	b.closeErr = err
+	return b
+}
+
+// Build returns BatchResults that serve the added results in order.
+func (b *BatchResultsBuilder) Build() pgx.BatchResults {
+	return &fakeBatchResults{results: append([]fakeBatchResult(nil), b.results...), closeErr: b.closeErr}
+}
+
+// BuildFor is like Build, and additionally runs the callbacks registered on
+// batch's queued queries (QueuedQuery.Exec, Query, QueryRow) for results that
+// are still unread at Close, as pgx does. Every read fails if the number of
+// results does not match the number of queued queries.
+func (b *BatchResultsBuilder) BuildFor(batch *pgx.Batch) pgx.BatchResults {
+	r := b.Build().(*fakeBatchResults)
+	r.batch = batch
+	if batch != nil && batch.Len() != len(r.results) {
+		r.mismatch = fmt.Errorf("neon.BatchResults: batch has %d queued queries but %d results were added", batch.Len(), len(r.results))
+	}
+	return r
+}
+
+type fakeBatchResults struct {
+	results  []fakeBatchResult
+	batch    *pgx.Batch
+	idx      int
+	closed   bool
+	closeErr error
+	mismatch error
+	firstErr error
+}
+
+var _ pgx.BatchResults = (*fakeBatchResults)(nil)
+
+func (r *fakeBatchResults) next() (fakeBatchResult, error) {
+	var err error
+	switch {
+	case r.closed:
+		err = errors.New("neon.BatchResults: batch already closed")
+	case r.mismatch != nil:
+		err = r.mismatch
+	case r.idx >= len(r.results):
+		err = errors.New("neon.BatchResults: no more results")
+	}
+	if err != nil {
+		r.noteErr(err)
+		return fakeBatchResult{}, err
+	}
+
+	res := r.results[r.idx]
+	r.idx++
+	if res.err != nil {
+		r.noteErr(res.err)
+	}
+	return res, res.err
+}
+
+func (r *fakeBatchResults) noteErr(err error) {
+	if r.firstErr == nil {
+		r.firstErr = err
+	}
+}
+
+func (r *fakeBatchResults) Exec() (pgconn.CommandTag, error) {
+	res, err := r.next()
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	if res.rows != nil {
+		return res.rows.tag, nil
+	}
+	return res.tag, nil
+}
+
+func (r *fakeBatchResults) Query() (pgx.Rows, error) {
+	res, err := r.next()
+	if err != nil {
+		return &ErrRows{ErrValue: err}, err
+	}
+	if res.rows == nil {
+		return NewRows(nil).WithCommandTag(res.tag).Build(), nil
+	}
+	return res.rows.Build(), nil
+}
+
+func (r *fakeBatchResults) QueryRow() pgx.Row {
+	rows, err := r.Query()
+	if err != nil {
+		return &ErrRow{Err: err}
+	}
+	return &rowsRow{rows: rows}
+}
+
+// Close consumes unread results, running any registered callbacks, and
+// returns the first error encountered.
+func (r *fakeBatchResults) Close() error {
+	if r.closed {
+		return r.firstErr
+	}
+	if r.mismatch != nil {
+		r.noteErr(r.mismatch)
+	} else {
+		for r.idx < len(r.results) {
+			idx := r.idx
+			if r.batch != nil && r.batch.QueuedQueries[idx].Fn != nil {
+				if err := r.batch.QueuedQueries[idx].Fn(r); err != nil {
+					r.noteErr(err)
+				}
+			}
+			if r.idx == idx {
+				_, _ = r.next()
+			}
+		}
+	}
+	r.closed = true
+
+	if r.firstErr != nil {
+		return r.firstErr
+	}
+	return r.closeErr
+}
+
+// errBatchResults implements pgx.BatchResults and fails every operation.
+type errBatchResults struct {
+	err error
+}
+
+func (r *errBatchResults) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, r.err }
+func (r *errBatchResults) Query() (pgx.Rows, error)         { return &ErrRows{ErrValue: r.err}, r.err }
+func (r *errBatchResults) QueryRow() pgx.Row                { return &ErrRow{Err: r.err} }
+func (r *errBatchResults) Close() error                     { return r.err }
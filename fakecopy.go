@@ -0,0 +1,117 @@
+package neon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CopyRecorder is a fake CopyFrom for TestDB.CopyFromFunc. It drains the
+// CopyFromSource the way Pool.CopyFrom does and records every copied row:
+//
+//	rec := neon.NewCopyRecorder()
+//	db := &neon.TestDB{CopyFromFunc: rec.CopyFrom}
+//	// ... code under test calls db.CopyFrom ...
+//	rec.AssertRowCount(t, "projects", 2)
+//	rows := rec.Rows("projects")
+//
+// CopyRecorder is safe for concurrent use.
+type CopyRecorder struct {
+	mu     sync.Mutex
+	copies []CopyCall
+	err    error
+}
+
+// CopyCall is a single CopyFrom recorded by a CopyRecorder.
+type CopyCall struct {
+	TableName   pgx.Identifier
+	ColumnNames []string
+	Rows        [][]any
+}
+
+// NewCopyRecorder creates an empty CopyRecorder.
+func NewCopyRecorder() *CopyRecorder {
+	return &CopyRecorder{}
+}
+
+// WithError makes every later CopyFrom fail with err without reading the
+// source. Failed copies are not recorded, since nothing would be committed.
+func (r *CopyRecorder) WithError(err error) *CopyRecorder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+	return r
+}
+
+// CopyFrom matches TestDB.CopyFromFunc. It fails, recording nothing, if ctx
+// is done, rowSrc reports an error, or a row's width differs from
+// columnNames.
+func (r *CopyRecorder) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	r.mu.Lock()
+	failWith := r.err
+	r.mu.Unlock()
+	if failWith != nil {
+		return 0, failWith
+	}
+
+	var rows [][]any
+	for rowSrc.Next() {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		values, err := rowSrc.Values()
+		if err != nil {
+			return 0, err
+		}
+		if len(values) != len(columnNames) {
+			return 0, fmt.Errorf("neon.CopyRecorder: row %d has %d values, want %d", len(rows), len(values), len(columnNames))
+		}
+		// Sources may reuse the values slice between rows.
+		rows = append(rows, append([]any(nil), values...))
+	}
+	if err := rowSrc.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	r.copies = append(r.copies, CopyCall{
+		TableName:   append(pgx.Identifier(nil), tableName...),
+		ColumnNames: append([]string(nil), columnNames...),
+		Rows:        rows,
+	})
+	r.mu.Unlock()
+	return int64(len(rows)), nil
+}
+
+// Copies returns the recorded copies in call order.
+func (r *CopyRecorder) Copies() []CopyCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]CopyCall(nil), r.copies...)
+}
+
+// Rows returns every row copied into table, across calls. table is matched
+// against the identifier parts joined by "." ("projects" or
+// "public.projects").
+func (r *CopyRecorder) Rows(table string) [][]any {
+	var rows [][]any
+	for _, c := range r.Copies() {
+		if strings.Join(c.TableName, ".") == table {
+			rows = append(rows, c.Rows...)
+		}
+	}
+	return rows
+}
+
+// AssertRowCount reports a test error unless exactly want rows were copied
+// into table.
+func (r *CopyRecorder) AssertRowCount(tb testing.TB, table string, want int) {
+	tb.Helper()
+	if got := len(r.Rows(table)); got != want {
+		tb.Errorf("neon.CopyRecorder: %d row(s) copied into %s, want %d", got, table, want)
+	}
+}
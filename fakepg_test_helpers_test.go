@@ -0,0 +1,700 @@
+package neon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// fakePGServer is an in-process PostgreSQL wire-protocol server that stands in
+// for a Neon endpoint in tests. It only speaks TLS (plaintext startups are
+// rejected, as Neon does), answers scripted queries over both the simple and
+// the extended protocol, and can simulate cold-start delays, dropped
+// connections, and a transaction-mode pooler that rejects prepared statements.
+type fakePGServer struct {
+	t         testing.TB
+	ln        net.Listener
+	tlsConfig *tls.Config
+	typeMap   *pgtype.Map
+	wg        sync.WaitGroup
+
+	mu             sync.Mutex
+	closed         bool
+	open           map[net.Conn]struct{}
+	handlers       []*fakePGHandler
+	startupDelay   time.Duration
+	dropNext       int
+	rejectExtended bool
+	conns          int
+	tlsConns       int
+	queries        []fakePGQuery
+}
+
+// fakePGResult is the scripted response to a matching query.
+type fakePGResult struct {
+	Columns []fakePGColumn
+	Rows    [][]any
+	// Tag defaults to "SELECT <rows>".
+	Tag string
+	// ErrCode and ErrMessage, when set, make the query fail with an
+	// ErrorResponse.
+	ErrCode    string
+	ErrMessage string
+	// Delay is applied before responding; Disconnect closes the connection
+	// instead of responding.
+	Delay      time.Duration
+	Disconnect bool
+}
+
+type fakePGColumn struct {
+	Name string
+	OID  uint32
+}
+
+type fakePGHandler struct {
+	match  *regexp.Regexp
+	result fakePGResult
+	// times limits how many queries the handler answers; 0 means unlimited.
+	times int
+	used  int
+}
+
+// fakePGQuery is a query observed by the server.
+type fakePGQuery struct {
+	SQL      string
+	Extended bool
+}
+
+func newFakePGServer(t testing.TB) *fakePGServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("fakepg: listen: %v", err)
+	}
+	s := &fakePGServer{
+		t:         t,
+		ln:        ln,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{fakePGCertificate(t)}},
+		typeMap:   pgtype.NewMap(),
+		open:      make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+// fakePGCertificate generates a throwaway self-signed certificate for
+// 127.0.0.1.
+func fakePGCertificate(t testing.TB) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("fakepg: generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fakepg"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("fakepg: create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// URL returns a connection string for the server with the given sslmode.
+func (s *fakePGServer) URL(sslmode string) string {
+	return fmt.Sprintf("postgresql://neondb_owner:fakepass@%s/neondb?sslmode=%s", s.ln.Addr(), sslmode)
+}
+
+// Handle scripts the response for queries matching pattern (a regular
+// expression). Handlers are tried in registration order; times limits how
+// often a handler answers (0 means unlimited).
+func (s *fakePGServer) Handle(pattern string, times int, result fakePGResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, &fakePGHandler{match: regexp.MustCompile(pattern), result: result, times: times})
+}
+
+// SetStartupDelay delays every new connection's startup, like a compute
+// waking from scale-to-zero.
+func (s *fakePGServer) SetStartupDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startupDelay = d
+}
+
+// DropNextConnections closes the next n connections right after the TLS
+// handshake.
+func (s *fakePGServer) DropNextConnections(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropNext = n
+}
+
+// RejectExtendedProtocol makes Parse fail with SQLSTATE 42P05, as prepared
+// statements do behind a transaction-mode pooler.
+func (s *fakePGServer) RejectExtendedProtocol() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectExtended = true
+}
+
+// Conns returns the number of accepted connections and how many of them
+// completed a TLS handshake.
+func (s *fakePGServer) Conns() (total, tlsConns int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns, s.tlsConns
+}
+
+// Queries returns the non-empty queries the server has executed.
+func (s *fakePGServer) Queries() []fakePGQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakePGQuery(nil), s.queries...)
+}
+
+func (s *fakePGServer) close() {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.open {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *fakePGServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns++
+		s.open[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.open, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (s *fakePGServer) serveConn(raw net.Conn) {
+	conn, ok := s.handshake(raw)
+	if !ok {
+		return
+	}
+	be := pgproto3.NewBackend(conn, conn)
+
+	s.mu.Lock()
+	delay := s.startupDelay
+	drop := s.dropNext > 0
+	if drop {
+		s.dropNext--
+	}
+	s.mu.Unlock()
+
+	msg, err := be.ReceiveStartupMessage()
+	if err != nil {
+		return
+	}
+	if _, ok := msg.(*pgproto3.StartupMessage); !ok {
+		return
+	}
+	if drop {
+		return
+	}
+	time.Sleep(delay)
+
+	be.Send(&pgproto3.AuthenticationOk{})
+	for _, p := range [][2]string{
+		{"server_version", "16.4"},
+		{"client_encoding", "UTF8"},
+		{"standard_conforming_strings", "on"},
+		{"DateStyle", "ISO, MDY"},
+		{"TimeZone", "UTC"},
+	} {
+		be.Send(&pgproto3.ParameterStatus{Name: p[0], Value: p[1]})
+	}
+	be.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if be.Flush() != nil {
+		return
+	}
+
+	(&fakePGSession{s: s, be: be, conn: conn, stmts: map[string]string{}, portals: map[string]fakePGPortal{}, txStatus: 'I'}).run()
+}
+
+// handshake answers the SSLRequest and upgrades to TLS. Plaintext startups are
+// rejected with an error, as Neon does.
+func (s *fakePGServer) handshake(raw net.Conn) (net.Conn, bool) {
+	be := pgproto3.NewBackend(raw, raw)
+	msg, err := be.ReceiveStartupMessage()
+	if err != nil {
+		return nil, false
+	}
+	switch msg.(type) {
+	case *pgproto3.SSLRequest:
+		if _, err := raw.Write([]byte{'S'}); err != nil {
+			return nil, false
+		}
+		tlsConn := tls.Server(raw, s.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, false
+		}
+		s.mu.Lock()
+		s.tlsConns++
+		s.mu.Unlock()
+		return tlsConn, true
+	case *pgproto3.StartupMessage:
+		be.Send(&pgproto3.ErrorResponse{Severity: "FATAL", Code: "28000", Message: "connection is insecure (try using `sslmode=require`)"})
+		_ = be.Flush()
+	}
+	return nil, false
+}
+
+type fakePGPortal struct {
+	sql     string
+	formats []int16
+}
+
+type fakePGSession struct {
+	s        *fakePGServer
+	be       *pgproto3.Backend
+	conn     net.Conn
+	stmts    map[string]string
+	portals  map[string]fakePGPortal
+	txStatus byte
+	// skipToSync discards extended-protocol messages after an error.
+	skipToSync bool
+	// failed records that the current statement sent an ErrorResponse.
+	failed bool
+}
+
+var errFakePGDisconnect = errors.New("fakepg: scripted disconnect")
+
+func (c *fakePGSession) run() {
+	for {
+		msg, err := c.be.Receive()
+		if err != nil {
+			return
+		}
+		if c.skipToSync {
+			if _, ok := msg.(*pgproto3.Sync); !ok {
+				continue
+			}
+			c.skipToSync = false
+		}
+
+		switch m := msg.(type) {
+		case *pgproto3.Query:
+			err = c.simpleQuery(m.String)
+		case *pgproto3.Parse:
+			err = c.parse(m)
+		case *pgproto3.Describe:
+			err = c.describe(m)
+		case *pgproto3.Bind:
+			c.portals[m.DestinationPortal] = fakePGPortal{sql: c.stmts[m.PreparedStatement], formats: m.ResultFormatCodes}
+			c.be.Send(&pgproto3.BindComplete{})
+		case *pgproto3.Execute:
+			err = c.execute(m)
+		case *pgproto3.Close:
+			c.be.Send(&pgproto3.CloseComplete{})
+		case *pgproto3.Sync:
+			c.be.Send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus})
+			err = c.be.Flush()
+		case *pgproto3.Flush:
+			err = c.be.Flush()
+		case *pgproto3.Terminate:
+			return
+		default:
+			c.fail("0A000", fmt.Sprintf("fakepg: unsupported message %T", msg))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *fakePGSession) fail(code, message string) {
+	c.failed = true
+	c.be.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: code, Message: message})
+}
+
+// simpleQuery runs each statement of a (possibly multi-statement) simple
+// query in order, stopping at the first error as Postgres does.
+func (c *fakePGSession) simpleQuery(sql string) error {
+	if isFakePGEmptyQuery(sql) {
+		c.be.Send(&pgproto3.EmptyQueryResponse{})
+	}
+	for _, stmt := range splitFakePGStatements(sql) {
+		if isFakePGEmptyQuery(stmt) {
+			continue
+		}
+		c.failed = false
+		var err error
+		if isFakePGCopyIn(stmt) {
+			err = c.copyIn(stmt)
+		} else {
+			err = c.respond(stmt, false, nil, true)
+		}
+		if err != nil {
+			return err
+		}
+		if c.failed {
+			break
+		}
+	}
+	c.be.Send(&pgproto3.ReadyForQuery{TxStatus: c.txStatus})
+	return c.be.Flush()
+}
+
+func isFakePGCopyIn(sql string) bool {
+	sql = strings.ToLower(sql)
+	return strings.HasPrefix(strings.TrimSpace(sql), "copy ") && strings.Contains(sql, "from stdin")
+}
+
+// copyIn runs a scripted COPY ... FROM STDIN. The client's data is read in
+// full and the binary-format tuples counted for the "COPY n" tag; a scripted
+// ErrCode fails the copy after the data arrives, as a constraint violation
+// would.
+func (c *fakePGSession) copyIn(sql string) error {
+	c.s.mu.Lock()
+	c.s.queries = append(c.s.queries, fakePGQuery{SQL: sql})
+	c.s.mu.Unlock()
+
+	res, ok := c.s.lookup(sql, true)
+	if !ok {
+		c.fail("42601", "fakepg: no scripted result for query")
+		return nil
+	}
+	c.be.Send(&pgproto3.CopyInResponse{OverallFormat: 1})
+	if err := c.be.Flush(); err != nil {
+		return err
+	}
+
+	var data []byte
+	for {
+		msg, err := c.be.Receive()
+		if err != nil {
+			return err
+		}
+		switch m := msg.(type) {
+		case *pgproto3.CopyData:
+			data = append(data, m.Data...)
+			continue
+		case *pgproto3.CopyFail:
+			c.fail("57014", "COPY from stdin failed: "+m.Message)
+			return nil
+		case *pgproto3.CopyDone:
+		default:
+			c.fail("08P01", fmt.Sprintf("fakepg: unexpected message %T during COPY", msg))
+			return nil
+		}
+		break
+	}
+
+	if res.ErrCode != "" {
+		c.fail(res.ErrCode, res.ErrMessage)
+		return nil
+	}
+	c.be.Send(&pgproto3.CommandComplete{CommandTag: []byte(fmt.Sprintf("COPY %d", countFakePGCopyTuples(data)))})
+	return nil
+}
+
+// countFakePGCopyTuples counts the tuples in binary COPY data.
+func countFakePGCopyTuples(data []byte) int {
+	const header = 11 + 4 // signature and flags
+	if len(data) < header+4 {
+		return 0
+	}
+	pos := header + 4 + int(binary.BigEndian.Uint32(data[header:]))
+	n := 0
+	for pos+2 <= len(data) {
+		fields := int16(binary.BigEndian.Uint16(data[pos:]))
+		pos += 2
+		if fields < 0 {
+			break
+		}
+		for i := 0; i < int(fields) && pos+4 <= len(data); i++ {
+			size := int32(binary.BigEndian.Uint32(data[pos:]))
+			pos += 4
+			if size > 0 {
+				pos += int(size)
+			}
+		}
+		n++
+	}
+	return n
+}
+
+// splitFakePGStatements splits sql on semicolons outside single-quoted
+// literals and line comments.
+func splitFakePGStatements(sql string) []string {
+	var stmts []string
+	start, inQuote, inComment := 0, false, false
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case inComment:
+			inComment = c != '\n'
+		case c == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			inComment = true
+		case c == ';':
+			stmts = append(stmts, sql[start:i])
+			start = i + 1
+		}
+	}
+	return append(stmts, sql[start:])
+}
+
+func (c *fakePGSession) parse(m *pgproto3.Parse) error {
+	c.s.mu.Lock()
+	reject := c.s.rejectExtended
+	c.s.mu.Unlock()
+	if reject {
+		c.fail("42P05", fmt.Sprintf(`prepared statement "%s" already exists`, m.Name))
+		c.skipToSync = true
+		return nil
+	}
+	c.stmts[m.Name] = m.Query
+	c.be.Send(&pgproto3.ParseComplete{})
+	return nil
+}
+
+func (c *fakePGSession) describe(m *pgproto3.Describe) error {
+	var sql string
+	var formats []int16
+	if m.ObjectType == 'S' {
+		sql = c.stmts[m.Name]
+		c.be.Send(&pgproto3.ParameterDescription{ParameterOIDs: fakePGParamOIDs(sql)})
+	} else {
+		p := c.portals[m.Name]
+		sql, formats = p.sql, p.formats
+	}
+
+	res, ok := c.s.lookup(sql, false)
+	if !ok || len(res.Columns) == 0 {
+		c.be.Send(&pgproto3.NoData{})
+		return nil
+	}
+	c.be.Send(&pgproto3.RowDescription{Fields: fakePGFields(res.Columns, formats)})
+	return nil
+}
+
+func (c *fakePGSession) execute(m *pgproto3.Execute) error {
+	p := c.portals[m.Portal]
+	if isFakePGEmptyQuery(p.sql) {
+		c.be.Send(&pgproto3.EmptyQueryResponse{})
+		return nil
+	}
+	return c.respond(p.sql, true, p.formats, false)
+}
+
+// respond runs a scripted query. describe controls whether a RowDescription
+// precedes the rows (simple protocol) or was already sent (extended).
+func (c *fakePGSession) respond(sql string, extended bool, formats []int16, describe bool) error {
+	c.s.mu.Lock()
+	c.s.queries = append(c.s.queries, fakePGQuery{SQL: sql, Extended: extended})
+	c.s.mu.Unlock()
+
+	if tag, ok := c.txControl(sql); ok {
+		c.be.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+		return nil
+	}
+
+	res, ok := c.s.lookup(sql, true)
+	if !ok {
+		c.fail("42601", "fakepg: no scripted result for query")
+		c.skipToSync = extended
+		return nil
+	}
+	if res.Delay > 0 {
+		time.Sleep(res.Delay)
+	}
+	if res.Disconnect {
+		c.conn.Close()
+		return errFakePGDisconnect
+	}
+	if res.ErrCode != "" {
+		c.fail(res.ErrCode, res.ErrMessage)
+		c.skipToSync = extended
+		return nil
+	}
+
+	if describe && len(res.Columns) > 0 {
+		c.be.Send(&pgproto3.RowDescription{Fields: fakePGFields(res.Columns, nil)})
+	}
+	for _, row := range res.Rows {
+		values := make([][]byte, len(row))
+		for i, v := range row {
+			if v == nil {
+				continue
+			}
+			format := fakePGFormat(formats, i)
+			buf, err := c.s.typeMap.Encode(res.Columns[i].OID, format, v, nil)
+			if err != nil {
+				c.s.t.Errorf("fakepg: encode column %s: %v", res.Columns[i].Name, err)
+			}
+			values[i] = buf
+		}
+		c.be.Send(&pgproto3.DataRow{Values: values})
+	}
+	tag := res.Tag
+	if tag == "" {
+		tag = fmt.Sprintf("SELECT %d", len(res.Rows))
+	}
+	c.be.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+	return nil
+}
+
+// txControl answers BEGIN/COMMIT/ROLLBACK without a script and tracks the
+// transaction status reported in ReadyForQuery.
+func (c *fakePGSession) txControl(sql string) (string, bool) {
+	word := strings.ToUpper(strings.Fields(sql + " x")[0])
+	switch word {
+	case "BEGIN":
+		c.txStatus = 'T'
+		return "BEGIN", true
+	case "COMMIT":
+		c.txStatus = 'I'
+		return "COMMIT", true
+	case "ROLLBACK":
+		c.txStatus = 'I'
+		return "ROLLBACK", true
+	}
+	return "", false
+}
+
+// lookup returns the first handler result matching sql. consume counts the
+// use against the handler's limit.
+func (s *fakePGServer) lookup(sql string, consume bool) (fakePGResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range s.handlers {
+		if h.times > 0 && h.used >= h.times {
+			continue
+		}
+		if !h.match.MatchString(sql) {
+			continue
+		}
+		if consume {
+			h.used++
+		}
+		return h.result, true
+	}
+	return fakePGResult{}, false
+}
+
+func fakePGFields(cols []fakePGColumn, formats []int16) []pgproto3.FieldDescription {
+	fields := make([]pgproto3.FieldDescription, len(cols))
+	for i, col := range cols {
+		fields[i] = pgproto3.FieldDescription{
+			Name:         []byte(col.Name),
+			DataTypeOID:  col.OID,
+			DataTypeSize: -1,
+			TypeModifier: -1,
+			Format:       fakePGFormat(formats, i),
+		}
+	}
+	return fields
+}
+
+func fakePGFormat(formats []int16, i int) int16 {
+	switch len(formats) {
+	case 0:
+		return pgtype.TextFormatCode
+	case 1:
+		return formats[0]
+	}
+	return formats[i]
+}
+
+var fakePGParamPattern = regexp.MustCompile(`\$(\d+)(?:::(\w+))?`)
+
+// fakePGCastOIDs maps explicit parameter casts ($1::bigint) to type OIDs;
+// uncast parameters are described as text.
+var fakePGCastOIDs = map[string]uint32{
+	"bigint": pgtype.Int8OID, "int8": pgtype.Int8OID,
+	"integer": pgtype.Int4OID, "int4": pgtype.Int4OID, "int": pgtype.Int4OID,
+	"boolean": pgtype.BoolOID, "bool": pgtype.BoolOID,
+}
+
+func fakePGParamOIDs(sql string) []uint32 {
+	casts := map[int]uint32{}
+	max := 0
+	for _, m := range fakePGParamPattern.FindAllStringSubmatch(sql, -1) {
+		var n int
+		fmt.Sscanf(m[1], "%d", &n)
+		if n > max {
+			max = n
+		}
+		if oid, ok := fakePGCastOIDs[strings.ToLower(m[2])]; ok {
+			casts[n] = oid
+		}
+	}
+	oids := make([]uint32, max)
+	for i := range oids {
+		oids[i] = pgtype.TextOID
+		if oid, ok := casts[i+1]; ok {
+			oids[i] = oid
+		}
+	}
+	return oids
+}
+
+func isFakePGEmptyQuery(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.Trim(line, ";")
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
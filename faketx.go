@@ -0,0 +1,217 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// FakeTx is a pgx.Tx for unit tests. Exec, Query, QueryRow, and (when DB
+// implements DBTX) CopyFrom and SendBatch are delegated to DB (typically a
+// *TestDB); Commit, Rollback, and savepoint activity are recorded and can be
+// inspected with Committed, RolledBack, and Events.
+//
+// Return a FakeTx from TestDB.BeginFunc or BeginTxFunc to exercise WithTx:
+//
+//	tx := &neon.FakeTx{DB: db, CommitErr: errors.New("serialization failure")}
+//	db.BeginTxFunc = func(context.Context, pgx.TxOptions) (pgx.Tx, error) { return tx, nil }
+//
+// Like a real transaction, a FakeTx is closed after the first Commit or
+// Rollback (even a failed one); later calls return pgx.ErrTxClosed. Begin on
+// an open FakeTx returns a nested FakeTx representing a savepoint that shares
+// the parent's DB and event log. FakeTx is safe for concurrent use.
+type FakeTx struct {
+	// DB receives Exec, Query, QueryRow, CopyFrom, and SendBatch. When nil
+	// those methods return ErrNotMocked.
+	DB DB
+
+	// CommitErr and RollbackErr, when set, are returned by Commit and
+	// Rollback respectively. The transaction is still closed.
+	CommitErr   error
+	RollbackErr error
+
+	mu         sync.Mutex
+	root       *FakeTx
+	savepoint  string
+	nextSP     int
+	closed     bool
+	committed  bool
+	rolledBack bool
+	events     []string
+}
+
+var _ pgx.Tx = (*FakeTx)(nil)
+
+func (t *FakeTx) log() *FakeTx {
+	if t.root != nil {
+		return t.root
+	}
+	return t
+}
+
+func (t *FakeTx) addEvent(event string) {
+	r := t.log()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// Events returns the recorded transaction events in order: "commit",
+// "rollback", "savepoint sp_N", "release sp_N", and "rollback to sp_N".
+// Calls on an already-closed transaction are not recorded. For a savepoint
+// FakeTx, Events returns the shared log of the outermost transaction.
+func (t *FakeTx) Events() []string {
+	r := t.log()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// Committed reports whether Commit was called on the open transaction.
+func (t *FakeTx) Committed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.committed
+}
+
+// RolledBack reports whether Rollback was called on the open transaction.
+func (t *FakeTx) RolledBack() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rolledBack
+}
+
+func (t *FakeTx) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// Begin starts a savepoint and returns it as a nested FakeTx.
+func (t *FakeTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if t.isClosed() {
+		return nil, pgx.ErrTxClosed
+	}
+
+	r := t.log()
+	r.mu.Lock()
+	r.nextSP++
+	name := fmt.Sprintf("sp_%d", r.nextSP)
+	r.mu.Unlock()
+
+	t.addEvent("savepoint " + name)
+	return &FakeTx{DB: t.DB, root: r, savepoint: name}, nil
+}
+
+// Commit closes the transaction and returns CommitErr.
+func (t *FakeTx) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return pgx.ErrTxClosed
+	}
+	t.closed = true
+	t.committed = true
+	t.mu.Unlock()
+
+	if t.savepoint != "" {
+		t.addEvent("release " + t.savepoint)
+	} else {
+		t.addEvent("commit")
+	}
+	return t.CommitErr
+}
+
+// Rollback closes the transaction and returns RollbackErr.
+func (t *FakeTx) Rollback(ctx context.Context) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return pgx.ErrTxClosed
+	}
+	t.closed = true
+	t.rolledBack = true
+	t.mu.Unlock()
+
+	if t.savepoint != "" {
+		t.addEvent("rollback to " + t.savepoint)
+	} else {
+		t.addEvent("rollback")
+	}
+	return t.RollbackErr
+}
+
+func (t *FakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if t.isClosed() {
+		return pgconn.CommandTag{}, pgx.ErrTxClosed
+	}
+	if t.DB == nil {
+		return pgconn.CommandTag{}, ErrNotMocked
+	}
+	return t.DB.Exec(ctx, sql, args...)
+}
+
+func (t *FakeTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if t.isClosed() {
+		return &ErrRows{ErrValue: pgx.ErrTxClosed}, pgx.ErrTxClosed
+	}
+	if t.DB == nil {
+		return &ErrRows{ErrValue: ErrNotMocked}, ErrNotMocked
+	}
+	return t.DB.Query(ctx, sql, args...)
+}
+
+func (t *FakeTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if t.isClosed() {
+		return &ErrRow{Err: pgx.ErrTxClosed}
+	}
+	if t.DB == nil {
+		return &ErrRow{Err: ErrNotMocked}
+	}
+	return t.DB.QueryRow(ctx, sql, args...)
+}
+
+// CopyFrom is delegated to DB when it implements DBTX (as *TestDB does).
+func (t *FakeTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	if t.isClosed() {
+		return 0, pgx.ErrTxClosed
+	}
+	if t.DB == nil {
+		return 0, ErrNotMocked
+	}
+	if d, ok := t.DB.(DBTX); ok {
+		return d.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	}
+	return 0, errors.New("neon.FakeTx: CopyFrom is not supported by DB")
+}
+
+// SendBatch is delegated to DB when it implements DBTX (as *TestDB does).
+func (t *FakeTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	if t.isClosed() {
+		return &errBatchResults{err: pgx.ErrTxClosed}
+	}
+	if t.DB == nil {
+		return &errBatchResults{err: ErrNotMocked}
+	}
+	if d, ok := t.DB.(DBTX); ok {
+		return d.SendBatch(ctx, b)
+	}
+	return &errBatchResults{err: errors.New("neon.FakeTx: SendBatch is not supported by DB")}
+}
+
+func (t *FakeTx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (t *FakeTx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	return nil, errors.New("neon.FakeTx: Prepare is not supported")
+}
+
+// Conn returns nil; a FakeTx has no underlying connection.
+func (t *FakeTx) Conn() *pgx.Conn {
+	return nil
+}
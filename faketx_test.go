@@ -0,0 +1,171 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func fakeTxDB(tx *FakeTx) *TestDB {
+	return &TestDB{
+		BeginTxFunc: func(context.Context, pgx.TxOptions) (pgx.Tx, error) { return tx, nil },
+	}
+}
+
+func TestFakeTx_DelegatesToDB(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &TestDB{
+		ExecFunc: func(context.Context, string, ...any) (pgconn.CommandTag, error) {
+			return pgconn.NewCommandTag("UPDATE 2"), nil
+		},
+		QueryFunc: func(context.Context, string, ...any) (pgx.Rows, error) {
+			return NewRows([]string{"id"}).AddRow(1).Build(), nil
+		},
+		QueryRowFunc: func(context.Context, string, ...any) pgx.Row {
+			return NewRow("Demo")
+		},
+	}
+	tx := &FakeTx{DB: db}
+
+	if tag, err := tx.Exec(ctx, "UPDATE projects SET x = 1"); err != nil || tag.RowsAffected() != 2 {
+		t.Fatalf("Exec tag=%v error=%v", tag, err)
+	}
+	rows, err := tx.Query(ctx, "SELECT id FROM projects")
+	if err != nil || !rows.Next() {
+		t.Fatalf("Query error=%v", err)
+	}
+	rows.Close()
+	var name string
+	if err := tx.QueryRow(ctx, "SELECT name FROM projects").Scan(&name); err != nil || name != "Demo" {
+		t.Fatalf("QueryRow name=%q error=%v", name, err)
+	}
+	db.AssertCallCount(t, "Exec", 1)
+	db.AssertCallCount(t, "Query", 1)
+	db.AssertCallCount(t, "QueryRow", 1)
+}
+
+func TestFakeTx_NilDBReturnsErrNotMocked(t *testing.T) {
+	t.Parallel()
+
+	tx := &FakeTx{}
+	if _, err := tx.Exec(context.Background(), "SELECT 1"); !errors.Is(err, ErrNotMocked) {
+		t.Fatalf("Exec error=%v", err)
+	}
+	if _, err := tx.Query(context.Background(), "SELECT 1"); !errors.Is(err, ErrNotMocked) {
+		t.Fatalf("Query error=%v", err)
+	}
+	if err := tx.QueryRow(context.Background(), "SELECT 1").Scan(new(int)); !errors.Is(err, ErrNotMocked) {
+		t.Fatalf("QueryRow error=%v", err)
+	}
+}
+
+func TestFakeTx_WithTxCommits(t *testing.T) {
+	t.Parallel()
+
+	tx := &FakeTx{}
+	if err := WithTx(context.Background(), fakeTxDB(tx), pgx.TxOptions{}, func(pgx.Tx) error { return nil }); err != nil {
+		t.Fatalf("WithTx error=%v", err)
+	}
+	if !tx.Committed() || tx.RolledBack() {
+		t.Fatalf("committed=%v rolledBack=%v", tx.Committed(), tx.RolledBack())
+	}
+	if got := tx.Events(); !reflect.DeepEqual(got, []string{"commit"}) {
+		t.Fatalf("events=%v", got)
+	}
+}
+
+func TestFakeTx_WithTxRollsBackOnError(t *testing.T) {
+	t.Parallel()
+
+	tx := &FakeTx{}
+	fnErr := errors.New("fn failed")
+	err := WithTx(context.Background(), fakeTxDB(tx), pgx.TxOptions{}, func(pgx.Tx) error { return fnErr })
+	if !errors.Is(err, fnErr) {
+		t.Fatalf("WithTx error=%v", err)
+	}
+	if tx.Committed() || !tx.RolledBack() {
+		t.Fatalf("committed=%v rolledBack=%v", tx.Committed(), tx.RolledBack())
+	}
+}
+
+func TestFakeTx_WithTxRollsBackAndRepanics(t *testing.T) {
+	t.Parallel()
+
+	tx := &FakeTx{}
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("recovered %v, want boom", p)
+			}
+		}()
+		_ = WithTx(context.Background(), fakeTxDB(tx), pgx.TxOptions{}, func(pgx.Tx) error { panic("boom") })
+	}()
+	if !tx.RolledBack() {
+		t.Fatal("expected rollback on panic")
+	}
+}
+
+func TestFakeTx_InjectedCommitFailure(t *testing.T) {
+	t.Parallel()
+
+	commitErr := errors.New("serialization failure")
+	tx := &FakeTx{CommitErr: commitErr}
+	err := WithTx(context.Background(), fakeTxDB(tx), pgx.TxOptions{}, func(pgx.Tx) error { return nil })
+
+	var safe *SafeError
+	if !errors.As(err, &safe) || safe.Error() != "neon: commit tx failed" || !errors.Is(err, commitErr) {
+		t.Fatalf("WithTx error=%v", err)
+	}
+	// The deferred rollback hits a closed transaction and is not recorded.
+	if got := tx.Events(); !reflect.DeepEqual(got, []string{"commit"}) {
+		t.Fatalf("events=%v", got)
+	}
+	if err := tx.Rollback(context.Background()); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("Rollback after commit error=%v", err)
+	}
+}
+
+func TestFakeTx_Savepoints(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	tx := &FakeTx{}
+
+	sp1, err := tx.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin error=%v", err)
+	}
+	sp2, err := sp1.Begin(ctx)
+	if err != nil {
+		t.Fatalf("nested Begin error=%v", err)
+	}
+	if err := sp2.Rollback(ctx); err != nil {
+		t.Fatalf("sp2 Rollback error=%v", err)
+	}
+	if err := sp1.Commit(ctx); err != nil {
+		t.Fatalf("sp1 Commit error=%v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("Commit error=%v", err)
+	}
+	if _, err := tx.Begin(ctx); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("Begin after commit error=%v", err)
+	}
+	if _, err := tx.Exec(ctx, "SELECT 1"); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("Exec after commit error=%v", err)
+	}
+
+	want := []string{"savepoint sp_1", "savepoint sp_2", "rollback to sp_2", "release sp_1", "commit"}
+	if got := tx.Events(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events=%v, want %v", got, want)
+	}
+	if !sp2.(*FakeTx).RolledBack() || !sp1.(*FakeTx).Committed() {
+		t.Fatal("savepoint state not recorded")
+	}
+}
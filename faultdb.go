@@ -0,0 +1,373 @@
+package neon
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Canned errors for FaultDB. They have the same shape as the errors a service
+// sees from pgx against Neon, so errors.Is/errors.As classification code can be
+// exercised unchanged.
+var (
+	// ErrFaultConnReset is a TCP connection reset (for example a compute
+	// restart or a dropped proxy connection).
+	ErrFaultConnReset error = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+	// ErrFaultColdStart is a connect timeout while a suspended compute wakes.
+	// It wraps context.DeadlineExceeded.
+	ErrFaultColdStart = fmt.Errorf("neon: timeout waiting for compute to start: %w", context.DeadlineExceeded)
+
+	// ErrFaultSerialization is SQLSTATE 40001 (serialization_failure).
+	ErrFaultSerialization error = &pgconn.PgError{
+		Severity: "ERROR",
+		Code:     "40001",
+		Message:  "could not serialize access due to concurrent update",
+	}
+
+	// ErrFaultPreparedStatementExists is SQLSTATE 42P05, the classic symptom
+	// of prepared statements leaking across PgBouncer transaction-mode
+	// backends.
+	ErrFaultPreparedStatementExists error = &pgconn.PgError{
+		Severity: "ERROR",
+		Code:     "42P05",
+		Message:  `prepared statement "stmtcache_1" already exists`,
+	}
+)
+
+// FaultConfig configures a FaultDB. The zero value injects nothing.
+type FaultConfig struct {
+	// Seed seeds the random source used for latency jitter and probabilistic
+	// faults. The same seed and call sequence yields the same faults.
+	Seed int64
+
+	// Latency is added before every faultable call; Jitter adds a further
+	// random delay in [0, Jitter). Waiting honors context cancellation.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// Script lists errors injected into successive faultable calls, in order.
+	// A nil entry lets that call through. Probabilistic faults apply once the
+	// script is exhausted.
+	Script []error
+
+	// ErrorRate is the probability (0..1) that a call fails with an error
+	// picked from Errors. When Errors is empty, the canned ErrFault* errors
+	// are used.
+	ErrorRate float64
+	Errors    []error
+
+	// CancelRate is the probability (0..1) that a call races with context
+	// cancellation. Half of these races cancel the context before the wrapped
+	// call runs; the other half let the call complete and then report
+	// context.Canceled, as when a caller gives up after the server has
+	// already applied the statement. A QueryRow that completes this way is
+	// sent through the wrapped DB's Query so the statement runs to the end.
+	CancelRate float64
+
+	// Methods limits faults to the named methods: "Exec", "Query",
+	// "QueryRow", "Begin", "BeginTx", "Ping", and "Commit" (on transactions
+	// returned by Begin/BeginTx). Empty means all of them.
+	Methods []string
+}
+
+// FaultDB is a DB decorator that injects latency, errors, and cancellation
+// races into calls on any DB (*Pool, *TestDB, *MockDB, ...). Transactions it
+// returns are wrapped too, so statements and Commit inside WithTx can fail.
+// Close is always passed through. FaultDB is safe for concurrent use, but
+// concurrent callers make the fault sequence depend on scheduling.
+type FaultDB struct {
+	db  DB
+	cfg FaultConfig
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	script   int
+	injected []InjectedFault
+}
+
+var _ DB = (*FaultDB)(nil)
+
+// InjectedFault records a fault that FaultDB injected.
+type InjectedFault struct {
+	Method string
+	Err    error
+}
+
+type faultPlan struct {
+	delay        time.Duration
+	err          error
+	cancelBefore bool
+	cancelAfter  bool
+}
+
+// NewFaultDB wraps db with fault injection configured by cfg.
+func NewFaultDB(db DB, cfg FaultConfig) *FaultDB {
+	if len(cfg.Errors) == 0 {
+		cfg.Errors = []error{ErrFaultConnReset, ErrFaultColdStart, ErrFaultSerialization, ErrFaultPreparedStatementExists}
+	}
+	return &FaultDB{
+		db:  db,
+		cfg: cfg,
+		rng: rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+// Injected returns the faults injected so far, in order. Latency alone is not
+// recorded.
+func (f *FaultDB) Injected() []InjectedFault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]InjectedFault(nil), f.injected...)
+}
+
+func (f *FaultDB) plan(method string) faultPlan {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.cfg.Methods) > 0 && !slices.Contains(f.cfg.Methods, method) {
+		return faultPlan{}
+	}
+
+	var p faultPlan
+	p.delay = f.cfg.Latency
+	if f.cfg.Jitter > 0 {
+		p.delay += time.Duration(f.rng.Int63n(int64(f.cfg.Jitter)))
+	}
+
+	switch {
+	case f.script < len(f.cfg.Script):
+		p.err = f.cfg.Script[f.script]
+		f.script++
+	case f.cfg.ErrorRate > 0 && f.rng.Float64() < f.cfg.ErrorRate:
+		p.err = f.cfg.Errors[f.rng.Intn(len(f.cfg.Errors))]
+	case f.cfg.CancelRate > 0 && f.rng.Float64() < f.cfg.CancelRate:
+		if f.rng.Intn(2) == 0 {
+			p.cancelBefore = true
+		} else {
+			p.cancelAfter = true
+		}
+	}
+
+	switch {
+	case p.err != nil:
+		f.injected = append(f.injected, InjectedFault{Method: method, Err: p.err})
+	case p.cancelBefore, p.cancelAfter:
+		f.injected = append(f.injected, InjectedFault{Method: method, Err: context.Canceled})
+	}
+	return p
+}
+
+// before applies the latency and pre-call faults for method. It returns the
+// context to pass to the wrapped call, a release func, and a non-nil error if
+// the call must not run.
+func (f *FaultDB) before(ctx context.Context, method string) (context.Context, faultPlan, context.CancelFunc, error) {
+	p := f.plan(method)
+
+	if p.delay > 0 {
+		timer := time.NewTimer(p.delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx, p, func() {}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if p.err != nil {
+		return ctx, p, func() {}, p.err
+	}
+
+	callCtx, cancel := context.WithCancel(ctx)
+	if p.cancelBefore {
+		cancel()
+	}
+	return callCtx, p, cancel, nil
+}
+
+// after converts a completed call into a cancellation when the plan says so.
+func (p faultPlan) after(err error) error {
+	if p.cancelAfter {
+		return context.Canceled
+	}
+	return err
+}
+
+func (f *FaultDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return faultExec(f, ctx, f.db.Exec, sql, args)
+}
+
+func (f *FaultDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return faultQuery(f, ctx, f.db.Query, sql, args)
+}
+
+func (f *FaultDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return faultQueryRow(f, ctx, f.db.Query, f.db.QueryRow, sql, args)
+}
+
+func (f *FaultDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	callCtx, p, cancel, err := f.before(ctx, "Begin")
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := f.db.Begin(callCtx)
+	return f.wrapTx(ctx, tx, p, err)
+}
+
+func (f *FaultDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	callCtx, p, cancel, err := f.before(ctx, "BeginTx")
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := f.db.BeginTx(callCtx, txOptions)
+	return f.wrapTx(ctx, tx, p, err)
+}
+
+func (f *FaultDB) wrapTx(ctx context.Context, tx pgx.Tx, p faultPlan, err error) (pgx.Tx, error) {
+	if err != nil {
+		return nil, err
+	}
+	if p.cancelAfter {
+		// The transaction was opened but the caller gave up; do not leak it.
+		_ = tx.Rollback(context.WithoutCancel(ctx))
+		return nil, context.Canceled
+	}
+	return &faultTx{Tx: tx, f: f}, nil
+}
+
+func (f *FaultDB) Ping(ctx context.Context) error {
+	callCtx, p, cancel, err := f.before(ctx, "Ping")
+	defer cancel()
+	if err != nil {
+		return err
+	}
+	return p.after(f.db.Ping(callCtx))
+}
+
+func (f *FaultDB) Close() {
+	f.db.Close()
+}
+
+func faultExec(f *FaultDB, ctx context.Context, exec func(context.Context, string, ...any) (pgconn.CommandTag, error), sql string, args []any) (pgconn.CommandTag, error) {
+	callCtx, p, cancel, err := f.before(ctx, "Exec")
+	defer cancel()
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	tag, err := exec(callCtx, sql, args...)
+	if err = p.after(err); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return tag, nil
+}
+
+func faultQuery(f *FaultDB, ctx context.Context, query func(context.Context, string, ...any) (pgx.Rows, error), sql string, args []any) (pgx.Rows, error) {
+	callCtx, p, cancel, err := f.before(ctx, "Query")
+	if err != nil {
+		cancel()
+		return &ErrRows{ErrValue: err}, err
+	}
+	rows, err := query(callCtx, sql, args...)
+	if p.cancelAfter {
+		if rows != nil {
+			rows.Close()
+		}
+		cancel()
+		return &ErrRows{ErrValue: context.Canceled}, context.Canceled
+	}
+	if err != nil {
+		cancel()
+		return rows, err
+	}
+	// The rows read lazily from callCtx, so cancel only once they are closed.
+	return &cancelOnCloseRows{Rows: rows, cancel: cancel}, nil
+}
+
+// faultQueryRow runs a cancel-after race through query rather than queryRow:
+// closing the rows reads the statement to completion whatever its columns,
+// which a Scan with no destinations would not.
+func faultQueryRow(f *FaultDB, ctx context.Context, query func(context.Context, string, ...any) (pgx.Rows, error), queryRow func(context.Context, string, ...any) pgx.Row, sql string, args []any) pgx.Row {
+	callCtx, p, cancel, err := f.before(ctx, "QueryRow")
+	if err != nil {
+		cancel()
+		return &ErrRow{Err: err}
+	}
+	if p.cancelAfter {
+		defer cancel()
+		if rows, err := query(callCtx, sql, args...); err == nil {
+			rows.Close()
+		}
+		return &ErrRow{Err: context.Canceled}
+	}
+	return &cancelAfterScanRow{row: queryRow(callCtx, sql, args...), cancel: cancel}
+}
+
+type cancelOnCloseRows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (r *cancelOnCloseRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+type cancelAfterScanRow struct {
+	row    pgx.Row
+	cancel context.CancelFunc
+}
+
+func (r *cancelAfterScanRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.row.Scan(dest...)
+}
+
+// faultTx applies the parent FaultDB's faults to statements, savepoints, and
+// Commit. Rollback is passed through so cleanup paths stay reliable.
+type faultTx struct {
+	pgx.Tx
+	f *FaultDB
+}
+
+func (t *faultTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return faultExec(t.f, ctx, t.Tx.Exec, sql, args)
+}
+
+func (t *faultTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return faultQuery(t.f, ctx, t.Tx.Query, sql, args)
+}
+
+func (t *faultTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return faultQueryRow(t.f, ctx, t.Tx.Query, t.Tx.QueryRow, sql, args)
+}
+
+func (t *faultTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	callCtx, p, cancel, err := t.f.before(ctx, "Begin")
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := t.Tx.Begin(callCtx)
+	return t.f.wrapTx(ctx, tx, p, err)
+}
+
+func (t *faultTx) Commit(ctx context.Context) error {
+	callCtx, p, cancel, err := t.f.before(ctx, "Commit")
+	defer cancel()
+	if err != nil {
+		// A failed COMMIT ends the transaction server-side; mirror that so the
+		// caller's deferred Rollback does not leave the wrapped tx open.
+		_ = t.Tx.Rollback(context.WithoutCancel(ctx))
+		return err
+	}
+	return p.after(t.Tx.Commit(callCtx))
+}
@@ -0,0 +1,241 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func okTestDB() *TestDB {
+	return &TestDB{
+		ExecFunc: func(context.Context, string, ...any) (pgconn.CommandTag, error) {
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		},
+		QueryFunc: func(context.Context, string, ...any) (pgx.Rows, error) {
+			return NewRows([]string{"id"}).AddRow(1).Build(), nil
+		},
+		QueryRowFunc: func(context.Context, string, ...any) pgx.Row {
+			return NewRow(1)
+		},
+		PingFunc: func(context.Context) error { return nil },
+	}
+}
+
+func TestFaultDB_ZeroConfigPassesThrough(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	inner := okTestDB()
+	db := NewFaultDB(inner, FaultConfig{})
+
+	if tag, err := db.Exec(ctx, "UPDATE x SET y = 1"); err != nil || tag.RowsAffected() != 1 {
+		t.Fatalf("Exec tag=%v error=%v", tag, err)
+	}
+	rows, err := db.Query(ctx, "SELECT id FROM x")
+	if err != nil {
+		t.Fatalf("Query error=%v", err)
+	}
+	rows.Close()
+	var id int
+	if err := db.QueryRow(ctx, "SELECT 1").Scan(&id); err != nil || id != 1 {
+		t.Fatalf("QueryRow id=%d error=%v", id, err)
+	}
+	if err := db.Ping(ctx); err != nil {
+		t.Fatalf("Ping error=%v", err)
+	}
+	if got := db.Injected(); len(got) != 0 {
+		t.Fatalf("injected=%v", got)
+	}
+	inner.AssertCallCount(t, "Exec", 1)
+}
+
+func TestFaultDB_ScriptedErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	inner := okTestDB()
+	db := NewFaultDB(inner, FaultConfig{
+		Script: []error{ErrFaultConnReset, nil, ErrFaultPreparedStatementExists},
+	})
+
+	_, err := db.Exec(ctx, "UPDATE x SET y = 1")
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("first Exec error=%v, want connection reset", err)
+	}
+	if _, err := db.Exec(ctx, "UPDATE x SET y = 1"); err != nil {
+		t.Fatalf("second Exec error=%v", err)
+	}
+	var pgErr *pgconn.PgError
+	if err := db.QueryRow(ctx, "SELECT 1").Scan(new(int)); !errors.As(err, &pgErr) || pgErr.Code != "42P05" {
+		t.Fatalf("QueryRow error=%v, want 42P05", err)
+	}
+	if _, err := db.Query(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("Query after script error=%v", err)
+	}
+
+	inner.AssertCallCount(t, "Exec", 1)
+	inner.AssertCallCount(t, "QueryRow", 0)
+	want := []InjectedFault{{Method: "Exec", Err: ErrFaultConnReset}, {Method: "QueryRow", Err: ErrFaultPreparedStatementExists}}
+	if got := db.Injected(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("injected=%v, want %v", got, want)
+	}
+}
+
+func TestFaultDB_SeedIsReproducible(t *testing.T) {
+	t.Parallel()
+
+	run := func(seed int64) []InjectedFault {
+		db := NewFaultDB(okTestDB(), FaultConfig{Seed: seed, ErrorRate: 0.5})
+		for i := 0; i < 50; i++ {
+			_, _ = db.Exec(context.Background(), "SELECT 1")
+		}
+		return db.Injected()
+	}
+
+	a, b := run(42), run(42)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("same seed produced different faults")
+	}
+	if len(a) == 0 || len(a) == 50 {
+		t.Fatalf("injected %d of 50 calls at rate 0.5", len(a))
+	}
+	if reflect.DeepEqual(a, run(7)) {
+		t.Fatal("different seeds produced identical faults")
+	}
+}
+
+func TestFaultDB_LatencyHonorsContext(t *testing.T) {
+	t.Parallel()
+
+	db := NewFaultDB(okTestDB(), FaultConfig{Latency: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := db.Ping(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Ping error=%v, want deadline exceeded", err)
+	}
+
+	slow := NewFaultDB(okTestDB(), FaultConfig{Latency: 20 * time.Millisecond, Jitter: 5 * time.Millisecond})
+	start := time.Now()
+	if err := slow.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping error=%v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("elapsed=%v, want >= 20ms", elapsed)
+	}
+}
+
+func TestFaultDB_CancellationRaces(t *testing.T) {
+	t.Parallel()
+
+	inner := &TestDB{
+		ExecFunc: func(ctx context.Context, _ string, _ ...any) (pgconn.CommandTag, error) {
+			if err := ctx.Err(); err != nil {
+				return pgconn.CommandTag{}, err
+			}
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		},
+	}
+	db := NewFaultDB(inner, FaultConfig{Seed: 1, CancelRate: 1})
+
+	for i := 0; i < 10; i++ {
+		if _, err := db.Exec(context.Background(), "UPDATE x SET y = 1"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Exec %d error=%v, want context.Canceled", i, err)
+		}
+	}
+	// Every call reaches the wrapped DB: some see a canceled context, others
+	// complete and have their result replaced.
+	inner.AssertCallCount(t, "Exec", 10)
+}
+
+// closeTrackingRows records whether the wrapped rows were closed.
+type closeTrackingRows struct {
+	pgx.Rows
+	closed *bool
+}
+
+func (r *closeTrackingRows) Close() {
+	r.Rows.Close()
+	*r.closed = true
+}
+
+func TestFaultDB_QueryRowCancelAfterRunsStatement(t *testing.T) {
+	t.Parallel()
+
+	var ran, closed bool
+	inner := &TestDB{
+		QueryFunc: func(ctx context.Context, _ string, _ ...any) (pgx.Rows, error) {
+			if err := ctx.Err(); err != nil {
+				return &ErrRows{ErrValue: err}, err
+			}
+			ran, closed = true, false
+			rows := NewRows([]string{"id", "name"}).AddRow(int64(1), "a").Build()
+			return &closeTrackingRows{Rows: rows, closed: &closed}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, _ string, _ ...any) pgx.Row {
+			return &ErrRow{Err: ctx.Err()}
+		},
+	}
+	db := NewFaultDB(inner, FaultConfig{Seed: 1, CancelRate: 1})
+
+	completed := 0
+	for i := 0; i < 10; i++ {
+		ran = false
+		var id int64
+		var name string
+		if err := db.QueryRow(context.Background(), "UPDATE x SET y = 1 RETURNING id, name").Scan(&id, &name); !errors.Is(err, context.Canceled) {
+			t.Fatalf("QueryRow %d error=%v, want context.Canceled", i, err)
+		}
+		if ran {
+			completed++
+			if !closed {
+				t.Fatalf("QueryRow %d returned context.Canceled before the statement finished", i)
+			}
+		}
+	}
+	if completed == 0 {
+		t.Fatal("no call let the statement complete before canceling")
+	}
+}
+
+func TestFaultDB_MethodFilterAndTxCommit(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	tx := &FakeTx{DB: okTestDB()}
+	inner := &TestDB{
+		BeginTxFunc: func(context.Context, pgx.TxOptions) (pgx.Tx, error) { return tx, nil },
+	}
+	db := NewFaultDB(inner, FaultConfig{ErrorRate: 1, Errors: []error{ErrFaultSerialization}, Methods: []string{"Commit"}})
+
+	err := WithTx(ctx, db, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "UPDATE x SET y = 1")
+		return err
+	})
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "40001" {
+		t.Fatalf("WithTx error=%v, want 40001", err)
+	}
+	if !tx.RolledBack() || tx.Committed() {
+		t.Fatalf("committed=%v rolledBack=%v", tx.Committed(), tx.RolledBack())
+	}
+	if got := db.Injected(); len(got) != 1 || got[0].Method != "Commit" {
+		t.Fatalf("injected=%v", got)
+	}
+}
+
+func TestFaultDB_ColdStartWrapsDeadline(t *testing.T) {
+	t.Parallel()
+
+	if !errors.Is(ErrFaultColdStart, context.DeadlineExceeded) {
+		t.Fatal("ErrFaultColdStart should wrap context.DeadlineExceeded")
+	}
+}
@@ -4,6 +4,7 @@ package neon
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/url"
@@ -328,3 +329,123 @@ CREATE TABLE %s (
 		}
 	})
 }
+
+func TestIntegration_NewTestTxRollsBack(t *testing.T) {
+	pooledURL, directURL := requireIntegrationEnv(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	pool, err := Connect(ctx, Config{
+		ConnectionString: pooledURL,
+		DirectURL:        directURL,
+		ConnectTimeout:   20 * time.Second,
+	})
+	mustNoErr(t, err, "connect pooled")
+	t.Cleanup(pool.Close)
+
+	var tableName string
+	t.Run("writes_inside_test_tx", func(t *testing.T) {
+		db := NewTestTx(t, pool)
+		tableName = integrationSchemaName(t)
+
+		_, err := db.Exec(ctx, fmt.Sprintf("CREATE TABLE public.%s (id INT PRIMARY KEY)", quoteIdent(tableName)))
+		mustNoErr(t, err, "create table in test tx")
+		_, err = db.Exec(ctx, fmt.Sprintf("INSERT INTO public.%s VALUES (1)", quoteIdent(tableName)))
+		mustNoErr(t, err, "insert in test tx")
+
+		sentinel := errors.New("rollback savepoint")
+		err = WithTx(ctx, db, pgx.TxOptions{}, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, fmt.Sprintf("INSERT INTO public.%s VALUES (2)", quoteIdent(tableName))); err != nil {
+				return err
+			}
+			return sentinel
+		})
+		mustIs(t, err, sentinel, "nested WithTx error")
+
+		var n int
+		mustNoErr(t, db.QueryRow(ctx, fmt.Sprintf("SELECT count(*) FROM public.%s", quoteIdent(tableName))).Scan(&n), "count rows")
+		if n != 1 {
+			t.Fatalf("rows=%d, want 1 after savepoint rollback", n)
+		}
+	})
+
+	var exists bool
+	mustNoErr(t, pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", "public."+tableName).Scan(&exists), "check table")
+	if exists {
+		t.Fatalf("table %s survived test transaction rollback", tableName)
+	}
+}
+
+func TestIntegration_NewTestSchemaPinsSearchPath(t *testing.T) {
+	pooledURL, directURL := requireIntegrationEnv(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// DATABASE_URL is normally the -pooler endpoint; NewTestSchema must
+	// still pin search_path by connecting to the direct endpoint.
+	var schema string
+	t.Run("isolated_schema", func(t *testing.T) {
+		pool := NewTestSchema(t, Config{
+			ConnectionString: pooledURL,
+			DirectURL:        directURL,
+			ConnectTimeout:   20 * time.Second,
+		})
+
+		mustNoErr(t, pool.QueryRow(ctx, "SELECT current_schema()").Scan(&schema), "current_schema")
+		if !strings.HasPrefix(schema, "test_") {
+			t.Fatalf("current_schema=%q, want test schema", schema)
+		}
+
+		_, err := pool.Exec(ctx, "CREATE TABLE items (id INT PRIMARY KEY)")
+		mustNoErr(t, err, "create unqualified table")
+
+		var n int
+		mustNoErr(t, pool.QueryRow(ctx, "SELECT count(*) FROM items").Scan(&n), "query unqualified table")
+	})
+
+	directConn, err := pgx.Connect(ctx, directURL)
+	mustNoErr(t, err, "connect direct")
+	defer directConn.Close(context.Background())
+
+	var exists bool
+	mustNoErr(t, directConn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", schema).Scan(&exists), "check schema")
+	if exists {
+		t.Fatalf("schema %s was not dropped", schema)
+	}
+}
+
+func TestIntegration_WebSocketTunnelRunsPostgresTLS(t *testing.T) {
+	pooledURL, directURL := requireIntegrationEnv(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Relies on Neon's proxy at wss://<host>/v2 relaying binary frames as a
+	// raw byte stream, so the SSLRequest and TLS handshake pass through it.
+	for name, url := range map[string]string{"pooled": pooledURL, "direct": directURL} {
+		t.Run(name, func(t *testing.T) {
+			pool, err := Connect(ctx, Config{
+				ConnectionString: url,
+				DirectURL:        directURL,
+				ConnectTimeout:   20 * time.Second,
+			}, WithWebSocket(""))
+			mustNoErr(t, err, "connect over websocket")
+			t.Cleanup(pool.Close)
+
+			conn, err := pool.pool.Acquire(ctx)
+			mustNoErr(t, err, "acquire")
+			defer conn.Release()
+			if _, ok := conn.Conn().PgConn().Conn().(*tls.Conn); !ok {
+				t.Fatalf("tunneled connection is %T, want *tls.Conn inside the WebSocket", conn.Conn().PgConn().Conn())
+			}
+
+			var one int
+			mustNoErr(t, conn.QueryRow(ctx, "SELECT 1").Scan(&one), "query over websocket")
+			if one != 1 {
+				t.Fatalf("SELECT 1 = %d", one)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultLeaderRetryInterval     = 5 * time.Second
+	defaultLeaderHeartbeatInterval = 5 * time.Second
+	defaultLeaderHeartbeatTimeout  = 5 * time.Second
+)
+
+// LeaderConfig configures a LeaderElector.
+type LeaderConfig struct {
+	// Name identifies the election. Instances using the same Name compete for
+	// one session advisory lock (see LockKey). Required.
+	Name string
+
+	// RetryInterval is how long a follower waits between attempts to become
+	// leader, and how long a former leader waits before competing again
+	// (default 5s).
+	RetryInterval time.Duration
+	// HeartbeatInterval is how often the leader checks that its lock session
+	// is alive (default 5s). HeartbeatTimeout bounds each check (default 5s).
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+
+	// OnElected is called in its own goroutine when this instance becomes
+	// leader. ctx is canceled when leadership ends; the lock is held until
+	// OnElected has returned, so leader-only work never overlaps another
+	// instance's unless the session was lost. OnElected may return early and
+	// leadership is kept.
+	OnElected func(ctx context.Context)
+	// OnLost is called after leadership ends, whether the session was lost
+	// or the elector stepped down.
+	OnLost func()
+	// OnError receives acquisition and heartbeat failures. The elector keeps
+	// retrying regardless.
+	OnError func(err error)
+}
+
+// leaderLock is the part of *AdvisoryLock the elector uses.
+type leaderLock interface {
+	Ping(ctx context.Context) error
+	Release(ctx context.Context) error
+	Done() <-chan struct{}
+}
+
+// LeaderElector elects one leader among instances sharing a LeaderConfig.Name
+// by holding a session advisory lock on a dedicated direct connection (see
+// Pool.TryLock). The leader heartbeats its session and steps down as soon as
+// a heartbeat fails or Run's context is done.
+type LeaderElector struct {
+	cfg     LeaderConfig
+	tryLock func(ctx context.Context, name string) (leaderLock, bool, error)
+	leader  atomic.Bool
+	running atomic.Bool
+}
+
+// NewLeaderElector returns an elector for pool. It fails fast when the pool's
+// DirectURL cannot host advisory locks (for example, a pooler endpoint).
+func NewLeaderElector(pool *Pool, cfg LeaderConfig) (*LeaderElector, error) {
+	if pool == nil {
+		return nil, errors.New("neon: leader election requires a pool")
+	}
+	if _, err := pool.lockSessionConfig(); err != nil {
+		return nil, err
+	}
+	return newLeaderElector(cfg, func(ctx context.Context, name string) (leaderLock, bool, error) {
+		l, ok, err := pool.TryLock(ctx, name, SessionLock)
+		if l == nil {
+			// Avoid a non-nil leaderLock holding a nil *AdvisoryLock.
+			return nil, ok, err
+		}
+		return l, ok, err
+	})
+}
+
+func newLeaderElector(cfg LeaderConfig, tryLock func(ctx context.Context, name string) (leaderLock, bool, error)) (*LeaderElector, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("neon: leader election requires a Name")
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = defaultLeaderRetryInterval
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = defaultLeaderHeartbeatInterval
+	}
+	if cfg.HeartbeatTimeout <= 0 {
+		cfg.HeartbeatTimeout = defaultLeaderHeartbeatTimeout
+	}
+	return &LeaderElector{cfg: cfg, tryLock: tryLock}, nil
+}
+
+// IsLeader reports whether this instance currently holds leadership.
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run competes for leadership until ctx is done, then steps down: OnElected's
+// context is canceled, Run waits for OnElected to return, releases the lock,
+// calls OnLost, and returns nil. Run may not be called concurrently.
+func (e *LeaderElector) Run(ctx context.Context) error {
+	if !e.running.CompareAndSwap(false, true) {
+		return errors.New("neon: LeaderElector is already running")
+	}
+	defer e.running.Store(false)
+
+	for ctx.Err() == nil {
+		if lock := e.acquire(ctx); lock != nil {
+			e.lead(ctx, lock)
+		}
+		if !sleepCtx(ctx, e.cfg.RetryInterval) {
+			break
+		}
+	}
+	return nil
+}
+
+// acquire makes one attempt at the lock. The lock's lifetime is detached from
+// ctx so that, on shutdown, lead can stop OnElected before the lock goes;
+// ctx still aborts the attempt itself.
+func (e *LeaderElector) acquire(ctx context.Context) leaderLock {
+	lockCtx, cancelLock := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancelLock)
+	lock, ok, err := e.tryLock(lockCtx, e.cfg.Name)
+	if !stop() || err != nil || !ok {
+		// Shutting down, failed, or held elsewhere. Canceling lockCtx
+		// releases a lock acquired as ctx ended.
+		cancelLock()
+		if err != nil && ctx.Err() == nil {
+			e.reportError(err)
+		}
+		return nil
+	}
+	return &detachedLock{leaderLock: lock, cancel: cancelLock}
+}
+
+// lead holds leadership until ctx is done or the lock session is lost.
+func (e *LeaderElector) lead(ctx context.Context, lock leaderLock) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	e.leader.Store(true)
+	workDone := make(chan struct{})
+	go func() {
+		defer close(workDone)
+		if e.cfg.OnElected != nil {
+			e.cfg.OnElected(leaderCtx)
+		}
+	}()
+
+	ticker := time.NewTicker(e.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for held := true; held; {
+		select {
+		case <-ctx.Done():
+			held = false
+		case <-lock.Done():
+			held = false
+		case <-ticker.C:
+			// The heartbeat is not tied to ctx: a ping aborted by shutdown
+			// would drop the session before OnElected has stopped.
+			pingCtx, cancelPing := context.WithTimeout(context.Background(), e.cfg.HeartbeatTimeout)
+			err := lock.Ping(pingCtx)
+			cancelPing()
+			if err != nil {
+				e.reportError(err)
+				held = false
+			}
+		}
+	}
+
+	cancel()
+	<-workDone
+	e.leader.Store(false)
+
+	releaseCtx, cancelRelease := context.WithTimeout(context.Background(), defaultRollbackTimeout)
+	defer cancelRelease()
+	if err := lock.Release(releaseCtx); err != nil && ctx.Err() == nil {
+		e.reportError(err)
+	}
+	if e.cfg.OnLost != nil {
+		e.cfg.OnLost()
+	}
+}
+
+func (e *LeaderElector) reportError(err error) {
+	if e.cfg.OnError != nil {
+		e.cfg.OnError(err)
+	}
+}
+
+// detachedLock cancels the lock's detached context once it is released.
+type detachedLock struct {
+	leaderLock
+	cancel context.CancelFunc
+}
+
+func (l *detachedLock) Release(ctx context.Context) error {
+	defer l.cancel()
+	return l.leaderLock.Release(ctx)
+}
+
+// sleepCtx waits for d and reports whether ctx is still live.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
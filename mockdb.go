@@ -0,0 +1,516 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MockDB is an expectation-based DB mock in the style of sqlmock.
+//
+// Register expectations with ExpectQuery, ExpectExec, ExpectBegin,
+// ExpectCommit, and ExpectRollback, run the code under test, then call
+// ExpectationsWereMet. By default expectations must be met in registration
+// order; call MatchExpectationsInOrder(false) to match in any order.
+//
+// ExpectQuery matches both Query and QueryRow. Statements issued on a
+// transaction returned by Begin/BeginTx are matched against the same
+// expectation list, so a typical transactional flow reads:
+//
+//	mock.ExpectBegin()
+//	mock.ExpectExec(`UPDATE projects`).WithArgs("Demo", 1).WillReturnResult(pgconn.NewCommandTag("UPDATE 1"))
+//	mock.ExpectCommit()
+//
+// Ping and Close are not tracked: Ping returns nil and Close is a no-op.
+// MockDB is safe for concurrent use.
+type MockDB struct {
+	mu           sync.Mutex
+	unordered    bool
+	expectations []mockExpectation
+}
+
+var _ DB = (*MockDB)(nil)
+
+// NewMockDB returns a MockDB that matches expectations in order.
+func NewMockDB() *MockDB {
+	return &MockDB{}
+}
+
+// MatchExpectationsInOrder controls whether expectations must be met in
+// registration order (the default) or in any order.
+func (m *MockDB) MatchExpectationsInOrder(inOrder bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unordered = !inOrder
+}
+
+// Argument matches a single query argument in WithArgs. Values that do not
+// implement Argument are compared with reflect.DeepEqual.
+type Argument interface {
+	Match(v any) bool
+}
+
+// AnyArg returns an Argument that matches any value.
+func AnyArg() Argument {
+	return anyArgument{}
+}
+
+type anyArgument struct{}
+
+func (anyArgument) Match(any) bool { return true }
+
+type mockExpectation interface {
+	kind() string
+	describe() string
+	fulfilled() bool
+}
+
+type expectationBase struct {
+	triggered bool
+	err       error
+}
+
+func (e *expectationBase) fulfilled() bool { return e.triggered }
+
+type sqlExpectation struct {
+	expectationBase
+	sqlRegex *regexp.Regexp
+	args     []any
+	hasArgs  bool
+}
+
+func (e *sqlExpectation) matchSQL(sql string, args []any) error {
+	if !e.sqlRegex.MatchString(sql) {
+		return fmt.Errorf("sql %q does not match %q", sql, e.sqlRegex.String())
+	}
+	if !e.hasArgs {
+		return nil
+	}
+	if len(args) != len(e.args) {
+		return fmt.Errorf("arg count %d != expected %d", len(args), len(e.args))
+	}
+	for i, want := range e.args {
+		if m, ok := want.(Argument); ok {
+			if !m.Match(args[i]) {
+				return fmt.Errorf("arg %d (%T) rejected by matcher", i, args[i])
+			}
+			continue
+		}
+		if !reflect.DeepEqual(want, args[i]) {
+			return fmt.Errorf("arg %d: got %#v, want %#v", i, args[i], want)
+		}
+	}
+	return nil
+}
+
+// ExpectedQuery is a Query/QueryRow expectation.
+type ExpectedQuery struct {
+	sqlExpectation
+	rows *RowsBuilder
+}
+
+func (e *ExpectedQuery) kind() string     { return "Query" }
+func (e *ExpectedQuery) describe() string { return "Query matching " + e.sqlRegex.String() }
+
+// WithArgs sets the expected arguments. Each value is either an Argument or
+// compared with reflect.DeepEqual.
+func (e *ExpectedQuery) WithArgs(args ...any) *ExpectedQuery {
+	e.args = args
+	e.hasArgs = true
+	return e
+}
+
+// WillReturnRows sets the rows returned by the query. A fresh cursor is built
+// for each match.
+func (e *ExpectedQuery) WillReturnRows(rows *RowsBuilder) *ExpectedQuery {
+	e.rows = rows
+	return e
+}
+
+// WillReturnError makes the query fail with err.
+func (e *ExpectedQuery) WillReturnError(err error) *ExpectedQuery {
+	e.err = err
+	return e
+}
+
+// ExpectedExec is an Exec expectation.
+type ExpectedExec struct {
+	sqlExpectation
+	tag pgconn.CommandTag
+}
+
+func (e *ExpectedExec) kind() string     { return "Exec" }
+func (e *ExpectedExec) describe() string { return "Exec matching " + e.sqlRegex.String() }
+
+// WithArgs sets the expected arguments. Each value is either an Argument or
+// compared with reflect.DeepEqual.
+func (e *ExpectedExec) WithArgs(args ...any) *ExpectedExec {
+	e.args = args
+	e.hasArgs = true
+	return e
+}
+
+// WillReturnResult sets the command tag returned by Exec.
+func (e *ExpectedExec) WillReturnResult(tag pgconn.CommandTag) *ExpectedExec {
+	e.tag = tag
+	return e
+}
+
+// WillReturnError makes Exec fail with err.
+func (e *ExpectedExec) WillReturnError(err error) *ExpectedExec {
+	e.err = err
+	return e
+}
+
+// ExpectedBegin is a Begin/BeginTx expectation.
+type ExpectedBegin struct {
+	expectationBase
+	opts    pgx.TxOptions
+	hasOpts bool
+}
+
+func (e *ExpectedBegin) kind() string     { return "Begin" }
+func (e *ExpectedBegin) describe() string { return "Begin" }
+
+// WithTxOptions requires BeginTx to be called with exactly opts.
+func (e *ExpectedBegin) WithTxOptions(opts pgx.TxOptions) *ExpectedBegin {
+	e.opts = opts
+	e.hasOpts = true
+	return e
+}
+
+// WillReturnError makes Begin fail with err.
+func (e *ExpectedBegin) WillReturnError(err error) *ExpectedBegin {
+	e.err = err
+	return e
+}
+
+// ExpectedCommit is a Tx.Commit expectation.
+type ExpectedCommit struct {
+	expectationBase
+}
+
+func (e *ExpectedCommit) kind() string     { return "Commit" }
+func (e *ExpectedCommit) describe() string { return "Commit" }
+
+// WillReturnError makes Commit fail with err.
+func (e *ExpectedCommit) WillReturnError(err error) *ExpectedCommit {
+	e.err = err
+	return e
+}
+
+// ExpectedRollback is a Tx.Rollback expectation.
+type ExpectedRollback struct {
+	expectationBase
+}
+
+func (e *ExpectedRollback) kind() string     { return "Rollback" }
+func (e *ExpectedRollback) describe() string { return "Rollback" }
+
+// WillReturnError makes Rollback fail with err.
+func (e *ExpectedRollback) WillReturnError(err error) *ExpectedRollback {
+	e.err = err
+	return e
+}
+
+// ExpectQuery registers a Query/QueryRow expectation. sqlRegex is a regular
+// expression matched against the SQL text; it panics if invalid.
+func (m *MockDB) ExpectQuery(sqlRegex string) *ExpectedQuery {
+	e := &ExpectedQuery{sqlExpectation: sqlExpectation{sqlRegex: regexp.MustCompile(sqlRegex)}}
+	m.push(e)
+	return e
+}
+
+// ExpectExec registers an Exec expectation. sqlRegex is a regular expression
+// matched against the SQL text; it panics if invalid.
+func (m *MockDB) ExpectExec(sqlRegex string) *ExpectedExec {
+	e := &ExpectedExec{sqlExpectation: sqlExpectation{sqlRegex: regexp.MustCompile(sqlRegex)}}
+	m.push(e)
+	return e
+}
+
+// ExpectBegin registers a Begin/BeginTx expectation. Begin on an open
+// transaction (a savepoint) also consumes an ExpectBegin.
+func (m *MockDB) ExpectBegin() *ExpectedBegin {
+	e := &ExpectedBegin{}
+	m.push(e)
+	return e
+}
+
+// ExpectCommit registers a Tx.Commit expectation.
+func (m *MockDB) ExpectCommit() *ExpectedCommit {
+	e := &ExpectedCommit{}
+	m.push(e)
+	return e
+}
+
+// ExpectRollback registers a Tx.Rollback expectation.
+func (m *MockDB) ExpectRollback() *ExpectedRollback {
+	e := &ExpectedRollback{}
+	m.push(e)
+	return e
+}
+
+func (m *MockDB) push(e mockExpectation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expectations = append(m.expectations, e)
+}
+
+// ExpectationsWereMet returns an error listing every expectation that was not
+// triggered.
+func (m *MockDB) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var missing []string
+	for _, e := range m.expectations {
+		if !e.fulfilled() {
+			missing = append(missing, e.describe())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("neon.MockDB: unmet expectations: %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+// match finds the expectation for a call. check returns nil when the
+// candidate (already known to be of the right kind) matches.
+func (m *MockDB) match(kind, call string, check func(mockExpectation) error) (mockExpectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var reasons []string
+	for _, e := range m.expectations {
+		if e.fulfilled() {
+			continue
+		}
+		if e.kind() != kind {
+			if !m.unordered {
+				return nil, fmt.Errorf("neon.MockDB: %s was not expected; next expectation is %s", call, e.describe())
+			}
+			continue
+		}
+		if err := check(e); err != nil {
+			if !m.unordered {
+				return nil, fmt.Errorf("neon.MockDB: %s does not match %s: %v", call, e.describe(), err)
+			}
+			reasons = append(reasons, err.Error())
+			continue
+		}
+		switch x := e.(type) {
+		case *ExpectedQuery:
+			x.triggered = true
+		case *ExpectedExec:
+			x.triggered = true
+		case *ExpectedBegin:
+			x.triggered = true
+		case *ExpectedCommit:
+			x.triggered = true
+		case *ExpectedRollback:
+			x.triggered = true
+		}
+		return e, nil
+	}
+
+	if len(reasons) > 0 {
+		return nil, fmt.Errorf("neon.MockDB: %s matched no expectation (%s)", call, strings.Join(reasons, "; "))
+	}
+	return nil, fmt.Errorf("neon.MockDB: %s was not expected", call)
+}
+
+func (m *MockDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	e, err := m.match("Exec", fmt.Sprintf("Exec(%q)", sql), func(e mockExpectation) error {
+		return e.(*ExpectedExec).matchSQL(sql, args)
+	})
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	x := e.(*ExpectedExec)
+	if x.err != nil {
+		return pgconn.CommandTag{}, x.err
+	}
+	return x.tag, nil
+}
+
+func (m *MockDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	e, err := m.match("Query", fmt.Sprintf("Query(%q)", sql), func(e mockExpectation) error {
+		return e.(*ExpectedQuery).matchSQL(sql, args)
+	})
+	if err != nil {
+		return &ErrRows{ErrValue: err}, err
+	}
+	x := e.(*ExpectedQuery)
+	if x.err != nil {
+		return &ErrRows{ErrValue: x.err}, x.err
+	}
+	if x.rows == nil {
+		return NewRows(nil).Build(), nil
+	}
+	return x.rows.Build(), nil
+}
+
+func (m *MockDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := m.Query(ctx, sql, args...)
+	if err != nil {
+		return &ErrRow{Err: err}
+	}
+	return &rowsRow{rows: rows}
+}
+
+func (m *MockDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return m.begin("Begin()", nil)
+}
+
+func (m *MockDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	return m.begin("BeginTx()", &txOptions)
+}
+
+func (m *MockDB) begin(call string, opts *pgx.TxOptions) (pgx.Tx, error) {
+	e, err := m.match("Begin", call, func(e mockExpectation) error {
+		x := e.(*ExpectedBegin)
+		if x.hasOpts && (opts == nil || *opts != x.opts) {
+			return errors.New("transaction options differ")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if x := e.(*ExpectedBegin); x.err != nil {
+		return nil, x.err
+	}
+	return &mockTx{db: m}, nil
+}
+
+func (m *MockDB) Ping(ctx context.Context) error { return nil }
+
+func (m *MockDB) Close() {}
+
+// rowsRow adapts pgx.Rows to pgx.Row: Scan reads the first row and closes the
+// cursor, returning pgx.ErrNoRows when there is none.
+type rowsRow struct {
+	rows pgx.Rows
+}
+
+func (r *rowsRow) Scan(dest ...any) error {
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
+
+// mockTx is the pgx.Tx returned by MockDB. Statements are matched against the
+// parent MockDB's expectations.
+type mockTx struct {
+	db *MockDB
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var _ pgx.Tx = (*mockTx)(nil)
+
+func (t *mockTx) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// close marks the transaction closed and reports whether it was open.
+func (t *mockTx) close() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.closed = true
+	return true
+}
+
+func (t *mockTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if t.isClosed() {
+		return nil, pgx.ErrTxClosed
+	}
+	return t.db.begin("Tx.Begin()", nil)
+}
+
+func (t *mockTx) Commit(ctx context.Context) error {
+	if !t.close() {
+		return pgx.ErrTxClosed
+	}
+	e, err := t.db.match("Commit", "Commit()", func(mockExpectation) error { return nil })
+	if err != nil {
+		return err
+	}
+	return e.(*ExpectedCommit).err
+}
+
+func (t *mockTx) Rollback(ctx context.Context) error {
+	if !t.close() {
+		return pgx.ErrTxClosed
+	}
+	e, err := t.db.match("Rollback", "Rollback()", func(mockExpectation) error { return nil })
+	if err != nil {
+		return err
+	}
+	return e.(*ExpectedRollback).err
+}
+
+func (t *mockTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if t.isClosed() {
+		return pgconn.CommandTag{}, pgx.ErrTxClosed
+	}
+	return t.db.Exec(ctx, sql, args...)
+}
+
+func (t *mockTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if t.isClosed() {
+		return &ErrRows{ErrValue: pgx.ErrTxClosed}, pgx.ErrTxClosed
+	}
+	return t.db.Query(ctx, sql, args...)
+}
+
+func (t *mockTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if t.isClosed() {
+		return &ErrRow{Err: pgx.ErrTxClosed}
+	}
+	return t.db.QueryRow(ctx, sql, args...)
+}
+
+func (t *mockTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return 0, errors.New("neon.MockDB: CopyFrom is not supported")
+}
+
+func (t *mockTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &errBatchResults{err: errors.New("neon.MockDB: SendBatch is not supported")}
+}
+
+func (t *mockTx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (t *mockTx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	return nil, errors.New("neon.MockDB: Prepare is not supported")
+}
+
+func (t *mockTx) Conn() *pgx.Conn {
+	return nil
+}
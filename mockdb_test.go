@@ -0,0 +1,230 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestMockDB_OrderedQueryExecAndTx(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mock := NewMockDB()
+	mock.ExpectQuery(`SELECT id, name FROM projects`).
+		WithArgs(AnyArg()).
+		WillReturnRows(NewRows([]string{"id", "name"}).AddRow(1, "Demo").AddRow(2, "Other"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE projects SET name`).
+		WithArgs("Renamed", 1).
+		WillReturnResult(pgconn.NewCommandTag("UPDATE 1"))
+	mock.ExpectCommit()
+
+	rows, err := mock.Query(ctx, "SELECT id, name FROM projects WHERE owner = $1", "u1")
+	if err != nil {
+		t.Fatalf("Query error=%v", err)
+	}
+	var n int
+	for rows.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("rows=%d, want 2", n)
+	}
+
+	err = WithTx(ctx, mock, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, "UPDATE projects SET name = $1 WHERE id = $2", "Renamed", 1)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() != 1 {
+			t.Errorf("RowsAffected=%d", tag.RowsAffected())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx error=%v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMockDB_OrderedRejectsOutOfOrderCall(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockDB()
+	mock.ExpectExec(`INSERT`)
+	mock.ExpectQuery(`SELECT`)
+
+	_, err := mock.Query(context.Background(), "SELECT 1")
+	if err == nil || !strings.Contains(err.Error(), "next expectation is Exec matching INSERT") {
+		t.Fatalf("Query error=%v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err == nil || !strings.Contains(err.Error(), "Exec matching INSERT; Query matching SELECT") {
+		t.Fatalf("ExpectationsWereMet=%v", err)
+	}
+}
+
+func TestMockDB_UnorderedMatchesAnyPending(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mock := NewMockDB()
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec(`INSERT INTO a`).WillReturnResult(pgconn.NewCommandTag("INSERT 0 1"))
+	mock.ExpectExec(`INSERT INTO b`).WithArgs(7).WillReturnResult(pgconn.NewCommandTag("INSERT 0 1"))
+
+	if _, err := mock.Exec(ctx, "INSERT INTO b VALUES ($1)", 8); err == nil || !strings.Contains(err.Error(), "matched no expectation") {
+		t.Fatalf("mismatched args error=%v", err)
+	}
+	if _, err := mock.Exec(ctx, "INSERT INTO b VALUES ($1)", 7); err != nil {
+		t.Fatalf("Exec b error=%v", err)
+	}
+	if _, err := mock.Exec(ctx, "INSERT INTO a VALUES (1)"); err != nil {
+		t.Fatalf("Exec a error=%v", err)
+	}
+	if _, err := mock.Exec(ctx, "INSERT INTO a VALUES (1)"); err == nil || !strings.Contains(err.Error(), "was not expected") {
+		t.Fatalf("extra Exec error=%v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMockDB_QueryRow(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mock := NewMockDB()
+	mock.ExpectQuery(`SELECT name`).WillReturnRows(NewRows([]string{"name"}).AddRow("Demo"))
+	mock.ExpectQuery(`SELECT name`).WillReturnRows(NewRows([]string{"name"}))
+	mock.ExpectQuery(`SELECT name`).WillReturnError(errors.New("boom"))
+
+	var name string
+	if err := mock.QueryRow(ctx, "SELECT name FROM projects").Scan(&name); err != nil || name != "Demo" {
+		t.Fatalf("Scan name=%q error=%v", name, err)
+	}
+	if err := mock.QueryRow(ctx, "SELECT name FROM projects").Scan(&name); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("empty Scan error=%v, want ErrNoRows", err)
+	}
+	if err := mock.QueryRow(ctx, "SELECT name FROM projects").Scan(&name); err == nil || err.Error() != "boom" {
+		t.Fatalf("error Scan error=%v", err)
+	}
+}
+
+func TestMockDB_TxErrorsAndRollback(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mock := NewMockDB()
+	mock.ExpectBegin().WillReturnError(errors.New("begin failed"))
+	mock.ExpectBegin().WithTxOptions(pgx.TxOptions{IsoLevel: pgx.Serializable})
+	mock.ExpectExec(`DELETE`).WillReturnError(errors.New("delete failed"))
+	mock.ExpectRollback()
+
+	if _, err := mock.Begin(ctx); err == nil || err.Error() != "begin failed" {
+		t.Fatalf("Begin error=%v", err)
+	}
+	err := WithTx(ctx, mock, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "DELETE FROM projects")
+		return err
+	})
+	if err == nil || err.Error() != "delete failed" {
+		t.Fatalf("WithTx error=%v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMockDB_TxOptionsMismatchAndClosedTx(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mock := NewMockDB()
+	mock.ExpectBegin().WithTxOptions(pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	mock.ExpectBegin()
+	mock.ExpectCommit().WillReturnError(errors.New("commit failed"))
+
+	if _, err := mock.Begin(ctx); err == nil || !strings.Contains(err.Error(), "transaction options differ") {
+		t.Fatalf("Begin error=%v", err)
+	}
+	if _, err := mock.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}); err != nil {
+		t.Fatalf("BeginTx error=%v", err)
+	}
+	tx, err := mock.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin error=%v", err)
+	}
+	if err := tx.Commit(ctx); err == nil || err.Error() != "commit failed" {
+		t.Fatalf("Commit error=%v", err)
+	}
+	if _, err := tx.Exec(ctx, "SELECT 1"); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("Exec after commit error=%v", err)
+	}
+	if err := tx.Rollback(ctx); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("Rollback after commit error=%v", err)
+	}
+}
+
+func TestMockDB_TxCloseIsRaceFree(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mock := NewMockDB()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	tx, err := mock.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin error=%v", err)
+	}
+	// Concurrent Rollback calls (as from a deferred rollback racing a
+	// cleanup) must close the transaction exactly once.
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- tx.Rollback(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var ok int
+	for err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case !errors.Is(err, pgx.ErrTxClosed):
+			t.Fatalf("Rollback error=%v", err)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d Rollback calls succeeded, want 1", ok)
+	}
+	if err := tx.Commit(ctx); !errors.Is(err, pgx.ErrTxClosed) {
+		t.Fatalf("Commit after rollback error=%v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMockDB_ExpectQueryPanicsOnInvalidRegex(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	NewMockDB().ExpectQuery(`(`)
+}
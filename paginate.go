@@ -0,0 +1,419 @@
+package neon
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	defaultPageLimit   = 20
+	defaultMaxPageSize = 100
+	minCursorSecretLen = 16
+)
+
+// ErrInvalidCursor is returned by Paginate for a cursor token that is
+// malformed, was signed with a different secret, or was issued for different
+// sort keys. Handlers should treat it as a client error (HTTP 400).
+var ErrInvalidCursor = errors.New("neon: invalid page cursor")
+
+// SortKey is one column of a keyset ordering.
+type SortKey struct {
+	// Column is a column name in the base query's result. It is quoted as an
+	// identifier, so it must match the result name exactly (usually lower
+	// case). Sort columns must be NOT NULL.
+	Column string
+	// Desc sorts the column in descending order.
+	Desc bool
+}
+
+// Paginator pages through query results with keyset (seek) pagination and
+// signed, opaque cursor tokens. Create one with NewPaginator and share it;
+// it is safe for concurrent use.
+type Paginator struct {
+	keys    []SortKey
+	secret  []byte
+	keysTag string
+
+	// DefaultLimit is the page size when PageRequest.Limit is zero (default 20).
+	DefaultLimit int
+	// MaxLimit caps PageRequest.Limit (default 100).
+	MaxLimit int
+}
+
+// NewPaginator returns a Paginator ordering by keys, in priority order. The
+// last key must make the ordering unique (typically the primary key), or rows
+// that tie on every key may be skipped between pages.
+//
+// secret signs cursor tokens with HMAC-SHA256 so clients cannot forge a
+// position; it must be at least 16 bytes and should come from configuration,
+// not source code.
+func NewPaginator(secret []byte, keys ...SortKey) (*Paginator, error) {
+	if len(secret) < minCursorSecretLen {
+		return nil, fmt.Errorf("neon: paginator secret must be at least %d bytes", minCursorSecretLen)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("neon: paginator needs at least one sort key")
+	}
+	h := sha256.New()
+	for i, k := range keys {
+		if k.Column == "" {
+			return nil, fmt.Errorf("neon: paginator sort key %d has an empty column", i)
+		}
+		fmt.Fprintf(h, "%s:%t;", k.Column, k.Desc)
+	}
+	return &Paginator{
+		keys:         slices.Clone(keys),
+		secret:       slices.Clone(secret),
+		keysTag:      hex.EncodeToString(h.Sum(nil)[:8]),
+		DefaultLimit: defaultPageLimit,
+		MaxLimit:     defaultMaxPageSize,
+	}, nil
+}
+
+// PageRequest selects a page. The zero value is the first page at the
+// default size.
+type PageRequest struct {
+	// Cursor is a Page.NextCursor or Page.PrevCursor from an earlier page, or
+	// empty for the first page.
+	Cursor string
+	// Limit is the page size; zero means Paginator.DefaultLimit.
+	Limit int
+}
+
+// Page is one page of results and the cursors to its neighbours.
+type Page[T any] struct {
+	Items []T
+	// NextCursor and PrevCursor are empty when there is no such page.
+	NextCursor string
+	PrevCursor string
+	HasNext    bool
+	HasPrev    bool
+	Limit      int
+}
+
+// Paginate runs one page of sql on db. sql is the base query without ORDER
+// BY or LIMIT; it may filter with $1..$n placeholders bound to args. It is
+// wrapped as a subquery:
+//
+//	SELECT * FROM (<sql>) AS neon_page WHERE <after cursor> ORDER BY <keys> LIMIT <limit+1>
+//
+// Postgres flattens the subquery, so an index matching the sort keys still
+// applies. Rows map to T as in QueryAll, and every sort key column must be
+// part of the result.
+//
+// Query failures are SafeError values; a bad cursor returns an error matching
+// ErrInvalidCursor.
+func Paginate[T any](ctx context.Context, db Querier, p *Paginator, req PageRequest, sql string, args ...any) (*Page[T], error) {
+	c, err := p.decodeCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := p.limit(req.Limit)
+	query, queryArgs := p.pageSQL(sql, args, c, limit)
+
+	rows, err := db.Query(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, &SafeError{msg: "neon: paginate failed", cause: err}
+	}
+	type keyed struct {
+		item T
+		key  []any
+	}
+	mapRow := rowMapper[T]()
+	got, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (keyed, error) {
+		key, err := p.rowKey(row)
+		if err != nil {
+			return keyed{}, err
+		}
+		item, err := mapRow(row)
+		return keyed{item: item, key: key}, err
+	})
+	if err != nil {
+		return nil, &SafeError{msg: "neon: paginate failed", cause: err}
+	}
+
+	more := len(got) > limit
+	if more {
+		got = got[:limit]
+	}
+	if c.backward {
+		slices.Reverse(got)
+	}
+
+	page := &Page[T]{Items: make([]T, len(got)), Limit: limit}
+	for i, k := range got {
+		page.Items[i] = k.item
+	}
+	if c.backward {
+		page.HasPrev, page.HasNext = more, true
+	} else {
+		page.HasNext, page.HasPrev = more, req.Cursor != ""
+	}
+	if len(got) == 0 {
+		// Nothing to anchor on (for example, every row after the cursor was
+		// deleted); a client can start over from the first page.
+		page.HasNext, page.HasPrev = false, false
+		return page, nil
+	}
+	if page.HasNext {
+		if page.NextCursor, err = p.encodeCursor(cursor{values: got[len(got)-1].key}); err != nil {
+			return nil, err
+		}
+	}
+	if page.HasPrev {
+		if page.PrevCursor, err = p.encodeCursor(cursor{backward: true, values: got[0].key}); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func (p *Paginator) limit(n int) int {
+	if n <= 0 {
+		n = p.DefaultLimit
+	}
+	if n <= 0 {
+		n = defaultPageLimit
+	}
+	if p.MaxLimit > 0 && n > p.MaxLimit {
+		n = p.MaxLimit
+	}
+	return n
+}
+
+// pageSQL wraps sql with the keyset predicate for c, the ordering, and a
+// LIMIT one past limit so the caller can tell whether another page exists.
+func (p *Paginator) pageSQL(sql string, args []any, c cursor, limit int) (string, []any) {
+	var b strings.Builder
+	b.WriteString("SELECT * FROM (\n")
+	b.WriteString(strings.TrimRight(sql, " \t\r\n;"))
+	b.WriteString("\n) AS neon_page")
+
+	queryArgs := slices.Clone(args)
+	if c.values != nil {
+		b.WriteString(" WHERE ")
+		b.WriteString(p.seekPredicate(c.backward, len(args)+1))
+		queryArgs = append(queryArgs, c.values...)
+	}
+
+	b.WriteString(" ORDER BY ")
+	for i, k := range p.keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(pgx.Identifier{k.Column}.Sanitize())
+		if k.Desc != c.backward {
+			b.WriteString(" DESC")
+		}
+	}
+	b.WriteString(" LIMIT " + strconv.Itoa(limit+1))
+	return b.String(), queryArgs
+}
+
+// seekPredicate returns the condition selecting rows after (or, backward,
+// before) the cursor position, whose values are bound from $first on. A row
+// comparison is used when every key sorts the same way, since Postgres can
+// match it to a composite index directly.
+func (p *Paginator) seekPredicate(backward bool, first int) string {
+	op := func(k SortKey) string {
+		if k.Desc != backward {
+			return "<"
+		}
+		return ">"
+	}
+	uniform := true
+	for _, k := range p.keys[1:] {
+		uniform = uniform && k.Desc == p.keys[0].Desc
+	}
+	if uniform {
+		cols := make([]string, len(p.keys))
+		params := make([]string, len(p.keys))
+		for i, k := range p.keys {
+			cols[i] = pgx.Identifier{k.Column}.Sanitize()
+			params[i] = "$" + strconv.Itoa(first+i)
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(cols, ", "), op(p.keys[0]), strings.Join(params, ", "))
+	}
+
+	// (a > $1) OR (a = $1 AND b < $2) OR ...
+	terms := make([]string, len(p.keys))
+	for i, k := range p.keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("%s = $%d", pgx.Identifier{p.keys[j].Column}.Sanitize(), first+j))
+		}
+		parts = append(parts, fmt.Sprintf("%s %s $%d", pgx.Identifier{k.Column}.Sanitize(), op(k), first+i))
+		terms[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// rowKey extracts the sort key values from row.
+func (p *Paginator) rowKey(row pgx.CollectableRow) ([]any, error) {
+	values, err := row.Values()
+	if err != nil {
+		return nil, err
+	}
+	fields := row.FieldDescriptions()
+	key := make([]any, len(p.keys))
+	for i, k := range p.keys {
+		idx := slices.IndexFunc(fields, func(f pgconn.FieldDescription) bool { return f.Name == k.Column })
+		if idx < 0 || idx >= len(values) {
+			return nil, fmt.Errorf("neon: sort key column %q is not in the query result", k.Column)
+		}
+		key[i] = values[idx]
+	}
+	return key, nil
+}
+
+// cursor is a decoded page position: the sort key values of the row to seek
+// past, and whether to page backward from it.
+type cursor struct {
+	backward bool
+	values   []any
+}
+
+type cursorPayload struct {
+	Keys     string        `json:"k"`
+	Backward bool          `json:"b,omitempty"`
+	Values   []cursorValue `json:"v"`
+}
+
+// cursorValue preserves the Go type of a key value across JSON, so it binds
+// to the query exactly as pgx returned it.
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+// encodeCursor returns base64url(payload) "." base64url(HMAC-SHA256).
+func (p *Paginator) encodeCursor(c cursor) (string, error) {
+	payload := cursorPayload{Keys: p.keysTag, Backward: c.backward, Values: make([]cursorValue, len(c.values))}
+	for i, v := range c.values {
+		cv, err := encodeCursorValue(v)
+		if err != nil {
+			return "", fmt.Errorf("neon: sort key column %q: %w", p.keys[i].Column, err)
+		}
+		payload.Values[i] = cv
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(raw) + "." + enc.EncodeToString(p.sign(raw)), nil
+}
+
+func (p *Paginator) decodeCursor(token string) (cursor, error) {
+	if token == "" {
+		return cursor{}, nil
+	}
+	enc := base64.RawURLEncoding
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return cursor{}, ErrInvalidCursor
+	}
+	raw, err := enc.DecodeString(body)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	mac, err := enc.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, p.sign(raw)) {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.Keys != p.keysTag || len(payload.Values) != len(p.keys) {
+		return cursor{}, ErrInvalidCursor
+	}
+	c := cursor{backward: payload.Backward, values: make([]any, len(payload.Values))}
+	for i, cv := range payload.Values {
+		v, err := decodeCursorValue(cv)
+		if err != nil {
+			return cursor{}, ErrInvalidCursor
+		}
+		c.values[i] = v
+	}
+	return c, nil
+}
+
+func (p *Paginator) sign(raw []byte) []byte {
+	h := hmac.New(sha256.New, p.secret)
+	h.Write(raw)
+	return h.Sum(nil)
+}
+
+func encodeCursorValue(v any) (cursorValue, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		if err != nil {
+			return cursorValue{}, err
+		}
+		v = dv
+	}
+	switch x := v.(type) {
+	case int64:
+		return cursorValue{"i", strconv.FormatInt(x, 10)}, nil
+	case int32:
+		return cursorValue{"i", strconv.FormatInt(int64(x), 10)}, nil
+	case int16:
+		return cursorValue{"i", strconv.FormatInt(int64(x), 10)}, nil
+	case int:
+		return cursorValue{"i", strconv.Itoa(x)}, nil
+	case float64:
+		return cursorValue{"f", strconv.FormatFloat(x, 'g', -1, 64)}, nil
+	case float32:
+		return cursorValue{"f", strconv.FormatFloat(float64(x), 'g', -1, 32)}, nil
+	case string:
+		return cursorValue{"s", x}, nil
+	case bool:
+		return cursorValue{"b", strconv.FormatBool(x)}, nil
+	case time.Time:
+		return cursorValue{"t", x.Format(time.RFC3339Nano)}, nil
+	case [16]byte:
+		return cursorValue{"u", hex.EncodeToString(x[:])}, nil
+	case []byte:
+		return cursorValue{"x", hex.EncodeToString(x)}, nil
+	case nil:
+		return cursorValue{}, errors.New("NULL values cannot be used as sort keys")
+	}
+	return cursorValue{}, fmt.Errorf("unsupported sort key type %T", v)
+}
+
+func decodeCursorValue(cv cursorValue) (any, error) {
+	switch cv.Type {
+	case "i":
+		return strconv.ParseInt(cv.Value, 10, 64)
+	case "f":
+		return strconv.ParseFloat(cv.Value, 64)
+	case "s":
+		return cv.Value, nil
+	case "b":
+		return strconv.ParseBool(cv.Value)
+	case "t":
+		return time.Parse(time.RFC3339Nano, cv.Value)
+	case "u":
+		b, err := hex.DecodeString(cv.Value)
+		if err != nil || len(b) != 16 {
+			return nil, ErrInvalidCursor
+		}
+		return [16]byte(b), nil
+	case "x":
+		return hex.DecodeString(cv.Value)
+	}
+	return nil, ErrInvalidCursor
+}
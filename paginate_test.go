@@ -0,0 +1,193 @@
+package neon
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var testCursorSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestNewPaginator_Validation(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewPaginator([]byte("short"), SortKey{Column: "id"}); err == nil {
+		t.Fatal("expected short secret to be rejected")
+	}
+	if _, err := NewPaginator(testCursorSecret); err == nil {
+		t.Fatal("expected missing sort keys to be rejected")
+	}
+	if _, err := NewPaginator(testCursorSecret, SortKey{Column: "id"}, SortKey{}); err == nil || !strings.Contains(err.Error(), "sort key 1") {
+		t.Fatalf("empty column err=%v", err)
+	}
+
+	p, err := NewPaginator(testCursorSecret, SortKey{Column: "id"})
+	if err != nil {
+		t.Fatalf("NewPaginator err=%v", err)
+	}
+	for in, want := range map[int]int{0: 20, -1: 20, 5: 5, 1000: 100} {
+		if got := p.limit(in); got != want {
+			t.Errorf("limit(%d)=%d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestPaginator_PageSQL(t *testing.T) {
+	t.Parallel()
+
+	uniform, _ := NewPaginator(testCursorSecret, SortKey{Column: "created_at", Desc: true}, SortKey{Column: "id", Desc: true})
+	mixed, _ := NewPaginator(testCursorSecret, SortKey{Column: "name"}, SortKey{Column: "id", Desc: true})
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := "SELECT id, name, created_at FROM projects WHERE owner_id = $1;\n"
+
+	tests := []struct {
+		p    *Paginator
+		c    cursor
+		want string
+	}{
+		{uniform, cursor{}, `ORDER BY "created_at" DESC, "id" DESC LIMIT 11`},
+		{uniform, cursor{values: []any{at, int64(7)}}, `WHERE ("created_at", "id") < ($2, $3) ORDER BY "created_at" DESC, "id" DESC LIMIT 11`},
+		{uniform, cursor{backward: true, values: []any{at, int64(7)}}, `WHERE ("created_at", "id") > ($2, $3) ORDER BY "created_at", "id" LIMIT 11`},
+		{mixed, cursor{values: []any{"b", int64(7)}}, `WHERE (("name" > $2) OR ("name" = $2 AND "id" < $3)) ORDER BY "name", "id" DESC LIMIT 11`},
+		{mixed, cursor{backward: true, values: []any{"b", int64(7)}}, `WHERE (("name" < $2) OR ("name" = $2 AND "id" > $3)) ORDER BY "name" DESC, "id" LIMIT 11`},
+	}
+	for _, tt := range tests {
+		sql, args := tt.p.pageSQL(base, []any{"u1"}, tt.c, 10)
+		want := "SELECT * FROM (\nSELECT id, name, created_at FROM projects WHERE owner_id = $1\n) AS neon_page " + tt.want
+		if sql != want {
+			t.Errorf("pageSQL=\n%s\nwant\n%s", sql, want)
+		}
+		if wantArgs := append([]any{"u1"}, tt.c.values...); !reflect.DeepEqual(args, wantArgs) {
+			t.Errorf("args=%v, want %v", args, wantArgs)
+		}
+	}
+}
+
+func TestPaginator_CursorTamperResistance(t *testing.T) {
+	t.Parallel()
+
+	p, _ := NewPaginator(testCursorSecret, SortKey{Column: "created_at"}, SortKey{Column: "id"})
+	at := time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC)
+	uuid := [16]byte{1, 2, 3}
+	token, err := p.encodeCursor(cursor{backward: true, values: []any{at, uuid}})
+	if err != nil {
+		t.Fatalf("encodeCursor err=%v", err)
+	}
+	c, err := p.decodeCursor(token)
+	if err != nil || !c.backward || !c.values[0].(time.Time).Equal(at) || c.values[1] != uuid {
+		t.Fatalf("decodeCursor=%+v err=%v", c, err)
+	}
+
+	body, sig, _ := strings.Cut(token, ".")
+	raw, _ := base64.RawURLEncoding.DecodeString(body)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), `"b":true`, `"b":false`, 1))) + "." + sig
+
+	otherSecret, _ := NewPaginator([]byte("fedcba9876543210fedcba9876543210"), SortKey{Column: "created_at"}, SortKey{Column: "id"})
+	otherKeys, _ := NewPaginator(testCursorSecret, SortKey{Column: "created_at", Desc: true}, SortKey{Column: "id"})
+	for name, tc := range map[string]struct {
+		p     *Paginator
+		token string
+	}{
+		"forged payload": {p, forged},
+		"other secret":   {otherSecret, token},
+		"other keys":     {otherKeys, token},
+		"no signature":   {p, body},
+		"garbage":        {p, "not-a-cursor!.x"},
+	} {
+		if _, err := tc.p.decodeCursor(tc.token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err=%v, want ErrInvalidCursor", name, err)
+		}
+	}
+
+	if _, err := p.encodeCursor(cursor{values: []any{nil, int64(1)}}); err == nil || !strings.Contains(err.Error(), `"created_at"`) {
+		t.Fatalf("NULL key err=%v", err)
+	}
+}
+
+type pageProject struct {
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func TestPaginate_ForwardAndBackward(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	rows := func(ids ...int64) *RowsBuilder {
+		rb := NewRows([]string{"id", "name", "created_at"})
+		for _, id := range ids {
+			rb.AddRow(id, "p", day(int(id)))
+		}
+		return rb
+	}
+
+	var next *RowsBuilder
+	var gotSQL string
+	var gotArgs []any
+	db := &TestDB{QueryFunc: func(_ context.Context, sql string, args ...any) (pgx.Rows, error) {
+		gotSQL, gotArgs = sql, args
+		return next.Build(), nil
+	}}
+	p, _ := NewPaginator(testCursorSecret, SortKey{Column: "created_at", Desc: true}, SortKey{Column: "id", Desc: true})
+	const base = "SELECT id, name, created_at FROM projects WHERE owner_id = $1"
+
+	// First page: newest first, one extra row signals another page.
+	next = rows(9, 8, 7)
+	page, err := Paginate[pageProject](ctx, db, p, PageRequest{Limit: 2}, base, "u1")
+	if err != nil {
+		t.Fatalf("page 1 err=%v", err)
+	}
+	if len(page.Items) != 2 || page.Items[1].ID != 8 || !page.HasNext || page.HasPrev || page.NextCursor == "" || page.PrevCursor != "" || page.Limit != 2 {
+		t.Fatalf("page 1=%+v", page)
+	}
+
+	// Second page seeks past the last item with typed arguments.
+	next = rows(7)
+	page, err = Paginate[pageProject](ctx, db, p, PageRequest{Cursor: page.NextCursor, Limit: 2}, base, "u1")
+	if err != nil {
+		t.Fatalf("page 2 err=%v", err)
+	}
+	if !reflect.DeepEqual(gotArgs, []any{"u1", day(8), int64(8)}) || !strings.Contains(gotSQL, `("created_at", "id") < ($2, $3)`) {
+		t.Fatalf("page 2 sql=%q args=%v", gotSQL, gotArgs)
+	}
+	if len(page.Items) != 1 || page.HasNext || !page.HasPrev || page.NextCursor != "" || page.PrevCursor == "" {
+		t.Fatalf("page 2=%+v", page)
+	}
+
+	// Paging back reverses the scan and restores display order.
+	next = rows(8, 9, 10)
+	page, err = Paginate[pageProject](ctx, db, p, PageRequest{Cursor: page.PrevCursor, Limit: 2}, base, "u1")
+	if err != nil {
+		t.Fatalf("prev page err=%v", err)
+	}
+	if !reflect.DeepEqual(gotArgs, []any{"u1", day(7), int64(7)}) || !strings.Contains(gotSQL, `> ($2, $3) ORDER BY "created_at", "id" LIMIT 3`) {
+		t.Fatalf("prev sql=%q args=%v", gotSQL, gotArgs)
+	}
+	if len(page.Items) != 2 || page.Items[0].ID != 9 || page.Items[1].ID != 8 || !page.HasNext || !page.HasPrev {
+		t.Fatalf("prev page=%+v", page)
+	}
+	c, _ := p.decodeCursor(page.NextCursor)
+	if c.backward || c.values[1] != int64(8) {
+		t.Fatalf("prev page next cursor=%+v", c)
+	}
+
+	// Errors: bad cursor, missing key column, query failure.
+	if _, err := Paginate[pageProject](ctx, db, p, PageRequest{Cursor: "bogus"}, base); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("bad cursor err=%v", err)
+	}
+	next = NewRows([]string{"name"}).AddRow("p")
+	if _, err := Paginate[pageProject](ctx, db, p, PageRequest{}, base); err == nil || !strings.Contains(errors.Unwrap(err).Error(), `"created_at" is not in the query result`) {
+		t.Fatalf("missing column err=%v", err)
+	}
+	if _, err := Paginate[pageProject](ctx, &TestDB{}, p, PageRequest{}, base, "secret-arg"); !errors.Is(err, ErrNotMocked) || err.Error() != "neon: paginate failed" {
+		t.Fatalf("query err=%v", err)
+	}
+}
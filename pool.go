@@ -2,6 +2,7 @@ package neon
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -13,9 +14,16 @@ import (
 type Pool struct {
 	pool      *pgxpool.Pool
 	directURL string
+
+	// poolerMode is set when connections use the simple protocol, where pgx
+	// sends a batch as one multi-statement query.
+	poolerMode bool
 }
 
-var _ DB = (*Pool)(nil)
+var (
+	_ DB   = (*Pool)(nil)
+	_ DBTX = (*Pool)(nil)
+)
 
 // DirectURL returns the resolved direct (non-pooled) URL.
 // It contains credentials and must be treated as secret material.
@@ -40,6 +48,40 @@ func (p *Pool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
 	return p.pool.QueryRow(ctx, sql, args...)
 }
 
+// CopyFrom bulk-loads rows into tableName using the COPY protocol. Rows are
+// streamed from rowSrc as they are sent, so large imports need not fit in
+// memory.
+//
+// Errors are wrapped in a SafeError naming only the table; the cause (for
+// example a *pgconn.PgError whose detail quotes a conflicting key) may carry
+// row data.
+func (p *Pool) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	n, err := p.pool.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	if err != nil {
+		return n, &SafeError{msg: fmt.Sprintf("neon: copy into %s failed", tableName.Sanitize()), cause: err}
+	}
+	return n, nil
+}
+
+// SendBatch sends all queued queries in b in a single round trip.
+// The caller must close the returned BatchResults; WithBatch does so.
+//
+// In pooler mode pgx joins the queries into one multi-statement simple query,
+// which Postgres runs as an implicit transaction, just as it does a pipelined
+// extended-protocol batch. To keep results aligned with queued queries, a
+// query containing more than one statement is rejected, and a query ending in
+// a -- comment is terminated so it cannot swallow the next one.
+func (p *Pool) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	if p.poolerMode {
+		safe, err := poolerSafeBatch(b)
+		if err != nil {
+			return &errBatchResults{err: err}
+		}
+		b = safe
+	}
+	return p.pool.SendBatch(ctx, b)
+}
+
 func (p *Pool) Begin(ctx context.Context) (pgx.Tx, error) {
 	return p.pool.Begin(ctx)
 }
@@ -0,0 +1,103 @@
+package neon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier is the read surface the typed query helpers need. DB, DBTX, and
+// pgx.Tx all satisfy it, so the helpers work on a *Pool, inside WithTx, and
+// against TestDB or MockDB.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// QueryOne runs sql and maps its single result row to T. Struct types are
+// mapped by column name with pgx.RowToStructByName (honoring `db` tags);
+// other types (int64, string, time.Time, pgtype values, sql.Scanner
+// implementations) are scanned from a single-column row.
+//
+// It fails with an error matching pgx.ErrNoRows when there is no row and
+// pgx.ErrTooManyRows when there is more than one. Errors are SafeError values
+// naming the helper but never the SQL arguments.
+func QueryOne[T any](ctx context.Context, db Querier, sql string, args ...any) (T, error) {
+	v, err := queryExactlyOne[T](ctx, db, sql, args)
+	if err != nil {
+		var zero T
+		return zero, &SafeError{msg: "neon: QueryOne failed", cause: err}
+	}
+	return v, nil
+}
+
+// QueryMaybe is like QueryOne but returns nil, and no error, when sql returns
+// no rows.
+func QueryMaybe[T any](ctx context.Context, db Querier, sql string, args ...any) (*T, error) {
+	v, err := queryExactlyOne[T](ctx, db, sql, args)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &SafeError{msg: "neon: QueryMaybe failed", cause: err}
+	}
+	return &v, nil
+}
+
+// QueryAll runs sql and maps every result row to T, with the same mapping as
+// QueryOne. No rows yields an empty, non-nil slice.
+func QueryAll[T any](ctx context.Context, db Querier, sql string, args ...any) ([]T, error) {
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, &SafeError{msg: "neon: QueryAll failed", cause: err}
+	}
+	out, err := pgx.CollectRows(rows, rowMapper[T]())
+	if err != nil {
+		return nil, &SafeError{msg: "neon: QueryAll failed", cause: err}
+	}
+	return out, nil
+}
+
+// Exists reports whether sql returns at least one row. The query is wrapped
+// as SELECT EXISTS (...) so the server stops at the first match; in tests,
+// answer it from TestDB.QueryRowFunc with NewRow(true).
+func Exists(ctx context.Context, db Querier, sql string, args ...any) (bool, error) {
+	var ok bool
+	if err := db.QueryRow(ctx, existsSQL(sql), args...).Scan(&ok); err != nil {
+		return false, &SafeError{msg: "neon: Exists failed", cause: err}
+	}
+	return ok, nil
+}
+
+// existsSQL wraps sql in SELECT EXISTS. Trailing semicolons are dropped and
+// the closing parenthesis goes on its own line so a trailing -- comment
+// cannot swallow it.
+func existsSQL(sql string) string {
+	sql = strings.TrimRight(sql, " \t\r\n;")
+	return "SELECT EXISTS (\n" + sql + "\n)"
+}
+
+func queryExactlyOne[T any](ctx context.Context, db Querier, sql string, args []any) (T, error) {
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return pgx.CollectExactlyOneRow(rows, rowMapper[T]())
+}
+
+var sqlScannerType = reflect.TypeFor[sql.Scanner]()
+
+// rowMapper picks pgx.RowToStructByName for plain struct types and
+// pgx.RowTo for everything else.
+func rowMapper[T any]() pgx.RowToFunc[T] {
+	t := reflect.TypeFor[T]()
+	if t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(sqlScannerType) {
+		return pgx.RowToStructByName[T]
+	}
+	return pgx.RowTo[T]
+}
@@ -0,0 +1,156 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type queryProject struct {
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	OwnerID   *string   `db:"owner_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func projectRows() *RowsBuilder {
+	owner := "u1"
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return NewRows([]string{"id", "name", "owner_id", "created_at"}).
+		AddRow(int64(1), "Alpha", &owner, created).
+		AddRow(int64(2), "Beta", nil, created)
+}
+
+func TestQueryHelpers_TestDB(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	var results []*RowsBuilder
+	db := &TestDB{QueryFunc: func(context.Context, string, ...any) (pgx.Rows, error) {
+		rb := results[0]
+		results = results[1:]
+		return rb.Build(), nil
+	}}
+
+	results = []*RowsBuilder{projectRows()}
+	all, err := QueryAll[queryProject](ctx, db, "SELECT * FROM projects")
+	if err != nil || len(all) != 2 || all[0].Name != "Alpha" || *all[0].OwnerID != "u1" || all[1].OwnerID != nil || all[1].CreatedAt.Year() != 2026 {
+		t.Fatalf("QueryAll=%+v err=%v", all, err)
+	}
+
+	results = []*RowsBuilder{NewRows([]string{"id", "name", "owner_id", "created_at"})}
+	if all, err := QueryAll[queryProject](ctx, db, "SELECT * FROM projects"); err != nil || all == nil || len(all) != 0 {
+		t.Fatalf("empty QueryAll=%#v err=%v", all, err)
+	}
+
+	results = []*RowsBuilder{NewRows([]string{"count"}).AddRow(int64(42))}
+	if n, err := QueryOne[int64](ctx, db, "SELECT count(*) FROM projects"); err != nil || n != 42 {
+		t.Fatalf("QueryOne[int64]=%d err=%v", n, err)
+	}
+
+	results = []*RowsBuilder{NewRows([]string{"name"}).AddRow("Alpha")}
+	if name, err := QueryOne[pgtype.Text](ctx, db, "SELECT name FROM projects"); err != nil || name.String != "Alpha" {
+		t.Fatalf("QueryOne[pgtype.Text]=%v err=%v", name, err)
+	}
+
+	results = []*RowsBuilder{projectRows()}
+	if _, err := QueryOne[queryProject](ctx, db, "SELECT * FROM projects", "secret-arg"); !errors.Is(err, pgx.ErrTooManyRows) {
+		t.Fatalf("QueryOne many err=%v", err)
+	} else if err.Error() != "neon: QueryOne failed" {
+		t.Fatalf("QueryOne error=%q", err.Error())
+	}
+
+	results = []*RowsBuilder{NewRows([]string{"id"})}
+	if _, err := QueryOne[int64](ctx, db, "SELECT id FROM projects"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("QueryOne none err=%v", err)
+	}
+
+	results = []*RowsBuilder{NewRows([]string{"id", "name", "owner_id", "created_at"})}
+	if p, err := QueryMaybe[queryProject](ctx, db, "SELECT * FROM projects WHERE id = $1", 9); err != nil || p != nil {
+		t.Fatalf("QueryMaybe none=%v err=%v", p, err)
+	}
+
+	results = []*RowsBuilder{NewRows([]string{"id", "name"}).AddRow(int64(3), "Gamma")}
+	if _, err := QueryMaybe[queryProject](ctx, db, "SELECT id, name FROM projects"); err == nil || !strings.HasPrefix(err.Error(), "neon: QueryMaybe failed") {
+		t.Fatalf("QueryMaybe missing columns err=%v", err)
+	}
+
+	queryErr := errors.New("relation does not exist")
+	db.QueryFunc = func(context.Context, string, ...any) (pgx.Rows, error) { return nil, queryErr }
+	if _, err := QueryAll[queryProject](ctx, db, "SELECT * FROM nope"); !errors.Is(err, queryErr) || err.Error() != "neon: QueryAll failed" {
+		t.Fatalf("QueryAll err=%v", err)
+	}
+
+	var safe *SafeError
+	if _, err := QueryOne[int64](ctx, &TestDB{}, "SELECT 1", "secret-arg"); !errors.As(err, &safe) || !errors.Is(err, ErrNotMocked) {
+		t.Fatalf("unmocked err=%v", err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := &TestDB{QueryRowFunc: func(_ context.Context, sql string, args ...any) pgx.Row {
+		return NewRow(strings.Contains(sql, "WHERE id = $1") && args[0] == int64(1))
+	}}
+
+	if ok, err := Exists(ctx, db, "SELECT 1 FROM projects WHERE id = $1;\n", int64(1)); err != nil || !ok {
+		t.Fatalf("Exists=%v err=%v", ok, err)
+	}
+	if ok, err := Exists(ctx, db, "SELECT 1 FROM projects WHERE id = $1 -- by id", int64(2)); err != nil || ok {
+		t.Fatalf("Exists=%v err=%v", ok, err)
+	}
+
+	calls := db.Calls()
+	if got := calls[0].SQL; got != "SELECT EXISTS (\nSELECT 1 FROM projects WHERE id = $1\n)" {
+		t.Fatalf("SQL=%q", got)
+	}
+	if got := calls[1].SQL; !strings.HasSuffix(got, "-- by id\n)") {
+		t.Fatalf("SQL=%q", got)
+	}
+
+	if _, err := Exists(ctx, &TestDB{}, "SELECT 1"); err == nil || err.Error() != "neon: Exists failed" || !errors.Is(err, ErrNotMocked) {
+		t.Fatalf("unmocked err=%v", err)
+	}
+}
+
+func TestQueryHelpers_OverWireInsideTx(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakePGServer(t)
+	srv.Handle(`^SELECT id, name FROM projects`, 0, fakePGResult{
+		Columns: []fakePGColumn{{Name: "id", OID: pgtype.Int8OID}, {Name: "name", OID: pgtype.TextOID}},
+		Rows:    [][]any{{int64(1), "Alpha"}, {int64(2), "Beta"}},
+	})
+	srv.Handle(`^SELECT EXISTS`, 0, fakePGResult{
+		Columns: []fakePGColumn{{Name: "exists", OID: pgtype.BoolOID}},
+		Rows:    [][]any{{true}},
+	})
+	pool := connectFakePG(t, Config{ConnectionString: srv.URL("require"), DirectURL: srv.URL("require"), ForcePoolerMode: true})
+	ctx := context.Background()
+
+	type project struct {
+		ID   int64
+		Name string `db:"name"`
+	}
+	err := WithTx(ctx, pool, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		all, err := QueryAll[project](ctx, tx, "SELECT id, name FROM projects")
+		if err != nil || len(all) != 2 || all[1] != (project{2, "Beta"}) {
+			t.Errorf("QueryAll=%+v err=%v", all, err)
+		}
+		ok, err := Exists(ctx, tx, "SELECT 1 FROM projects WHERE owner_id = $1", "u1")
+		if err != nil || !ok {
+			t.Errorf("Exists=%v err=%v", ok, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx err=%v", err)
+	}
+}
@@ -0,0 +1,706 @@
+package neon
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const replayFileVersion = 1
+
+// ReplayDB is a record-and-replay DB for golden-file tests.
+//
+// In record mode (NewRecordingDB) it wraps a real DB, passes every call
+// through, and captures each statement with its normalized args, result
+// columns and types, rows, command tag, and errors. Save writes the capture to
+// a golden file. In replay mode (LoadReplayDB) it serves those results from the
+// file with no database at all.
+//
+// Replay matches a call to the first unused recorded interaction with the same
+// method, whitespace-normalized SQL, and normalized args; repeated identical
+// statements replay in recorded order. Transactions are recorded and replayed
+// as Begin/Commit/Rollback interactions. Query results are read eagerly while
+// recording; QueryRow goes through the wrapped DB's QueryRow and is recorded
+// when its row is scanned. Ping is passed through when recording and always
+// succeeds when replaying.
+//
+// Recording fails with an error for result values whose type the golden file
+// cannot represent, rather than replaying them as a different type.
+//
+// Golden files contain query args and result data but never connection
+// strings; recorded error messages are redacted. ReplayDB is safe for
+// concurrent use.
+type ReplayDB struct {
+	db DB // nil in replay mode
+
+	mu           sync.Mutex
+	interactions []replayInteraction
+	used         []bool
+}
+
+var _ DB = (*ReplayDB)(nil)
+
+type replayFile struct {
+	Version      int                 `json:"version"`
+	Interactions []replayInteraction `json:"interactions"`
+}
+
+type replayInteraction struct {
+	Method     string          `json:"method"`
+	SQL        string          `json:"sql,omitempty"`
+	Args       json.RawMessage `json:"args,omitempty"`
+	Columns    []string        `json:"columns,omitempty"`
+	Types      []uint32        `json:"types,omitempty"`
+	Rows       [][]replayValue `json:"rows,omitempty"`
+	CommandTag string          `json:"command_tag,omitempty"`
+	Error      *replayError    `json:"error,omitempty"`
+	RowsError  *replayError    `json:"rows_error,omitempty"`
+}
+
+type replayError struct {
+	Message string `json:"message"`
+	// Code is the SQLSTATE when the error was a *pgconn.PgError.
+	Code string `json:"code,omitempty"`
+}
+
+// replayValue is a result value tagged with its Go type so replayed rows scan
+// like the recorded ones.
+type replayValue struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v,omitempty"`
+}
+
+// NewRecordingDB returns a ReplayDB in record mode that wraps db.
+func NewRecordingDB(db DB) *ReplayDB {
+	return &ReplayDB{db: db}
+}
+
+// LoadReplayDB reads a golden file written by Save and returns a ReplayDB in
+// replay mode.
+func LoadReplayDB(path string) (*ReplayDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("neon.ReplayDB: read golden file: %w", err)
+	}
+	var f replayFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("neon.ReplayDB: decode golden file %s: %w", path, err)
+	}
+	if f.Version != replayFileVersion {
+		return nil, fmt.Errorf("neon.ReplayDB: golden file %s has version %d, want %d", path, f.Version, replayFileVersion)
+	}
+	for i, it := range f.Interactions {
+		// Save indents args; compact them back to the form replay compares.
+		if len(it.Args) > 0 {
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, it.Args); err != nil {
+				return nil, fmt.Errorf("neon.ReplayDB: decode golden file %s: %w", path, err)
+			}
+			f.Interactions[i].Args = buf.Bytes()
+		}
+	}
+	return &ReplayDB{interactions: f.Interactions, used: make([]bool, len(f.Interactions))}, nil
+}
+
+// Save writes the recorded interactions to path as indented JSON readable only
+// by the owner, creating parent directories as needed. It is only valid in
+// record mode.
+func (r *ReplayDB) Save(path string) error {
+	if r.db == nil {
+		return errors.New("neon.ReplayDB: Save is only valid in record mode")
+	}
+
+	r.mu.Lock()
+	f := replayFile{Version: replayFileVersion, Interactions: append([]replayInteraction(nil), r.interactions...)}
+	r.mu.Unlock()
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("neon.ReplayDB: encode golden file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("neon.ReplayDB: create golden file directory: %w", err)
+	}
+	// Golden files hold captured row data; keep them private like env files.
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("neon.ReplayDB: write golden file: %w", err)
+	}
+	return nil
+}
+
+// Unreplayed returns the number of recorded interactions not yet served in
+// replay mode.
+func (r *ReplayDB) Unreplayed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *ReplayDB) record(it replayInteraction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, it)
+}
+
+func (r *ReplayDB) replay(method, sql string, args []any) (replayInteraction, error) {
+	normSQL := normalizeReplaySQL(sql)
+	normArgs, err := normalizeReplayArgs(args)
+	if err != nil {
+		return replayInteraction{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, it := range r.interactions {
+		if r.used[i] || it.Method != method || it.SQL != normSQL || !bytes.Equal(it.Args, normArgs) {
+			continue
+		}
+		r.used[i] = true
+		return it, nil
+	}
+	if sql == "" {
+		return replayInteraction{}, fmt.Errorf("neon.ReplayDB: no recorded %s", method)
+	}
+	return replayInteraction{}, fmt.Errorf("neon.ReplayDB: no recorded %s(%q) with args %s", method, normSQL, normArgs)
+}
+
+func (r *ReplayDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if r.db != nil {
+		return r.recordExec(ctx, r.db.Exec, sql, args)
+	}
+	return r.replayExec(sql, args)
+}
+
+func (r *ReplayDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if r.db != nil {
+		return r.recordQuery(ctx, "Query", r.db.Query, sql, args)
+	}
+	return r.replayQuery("Query", sql, args)
+}
+
+func (r *ReplayDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if r.db != nil {
+		return r.recordQueryRow(ctx, r.db.QueryRow, sql, args)
+	}
+	rows, err := r.replayQuery("QueryRow", sql, args)
+	if err != nil {
+		return &ErrRow{Err: err}
+	}
+	return &rowsRow{rows: rows}
+}
+
+func (r *ReplayDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	if r.db != nil {
+		tx, err := r.db.Begin(ctx)
+		return r.recordBegin(tx, err)
+	}
+	return r.replayBegin()
+}
+
+func (r *ReplayDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	if r.db != nil {
+		tx, err := r.db.BeginTx(ctx, txOptions)
+		return r.recordBegin(tx, err)
+	}
+	return r.replayBegin()
+}
+
+func (r *ReplayDB) Ping(ctx context.Context) error {
+	if r.db != nil {
+		return r.db.Ping(ctx)
+	}
+	return nil
+}
+
+func (r *ReplayDB) Close() {
+	if r.db != nil {
+		r.db.Close()
+	}
+}
+
+func (r *ReplayDB) recordExec(ctx context.Context, exec func(context.Context, string, ...any) (pgconn.CommandTag, error), sql string, args []any) (pgconn.CommandTag, error) {
+	normArgs, err := normalizeReplayArgs(args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+
+	tag, err := exec(ctx, sql, args...)
+	r.record(replayInteraction{
+		Method:     "Exec",
+		SQL:        normalizeReplaySQL(sql),
+		Args:       normArgs,
+		CommandTag: tag.String(),
+		Error:      encodeReplayError(err),
+	})
+	return tag, err
+}
+
+func (r *ReplayDB) replayExec(sql string, args []any) (pgconn.CommandTag, error) {
+	it, err := r.replay("Exec", sql, args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	if it.Error != nil {
+		return pgconn.CommandTag{}, it.Error.err()
+	}
+	return pgconn.NewCommandTag(it.CommandTag), nil
+}
+
+func (r *ReplayDB) recordQuery(ctx context.Context, method string, query func(context.Context, string, ...any) (pgx.Rows, error), sql string, args []any) (pgx.Rows, error) {
+	normArgs, err := normalizeReplayArgs(args)
+	if err != nil {
+		return &ErrRows{ErrValue: err}, err
+	}
+	it := replayInteraction{Method: method, SQL: normalizeReplaySQL(sql), Args: normArgs}
+
+	rows, err := query(ctx, sql, args...)
+	if err != nil {
+		it.Error = encodeReplayError(err)
+		r.record(it)
+		return &ErrRows{ErrValue: err}, err
+	}
+
+	var encodeErr error
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			break
+		}
+		row := make([]replayValue, len(values))
+		for i, v := range values {
+			if row[i], err = encodeReplayValue(v); err != nil && encodeErr == nil {
+				encodeErr = err
+			}
+		}
+		it.Rows = append(it.Rows, row)
+	}
+	rows.Close()
+	for _, fd := range rows.FieldDescriptions() {
+		it.Columns = append(it.Columns, fd.Name)
+		it.Types = append(it.Types, fd.DataTypeOID)
+	}
+	it.RowsError = encodeReplayError(rows.Err())
+	it.CommandTag = rows.CommandTag().String()
+	if encodeErr != nil {
+		return &ErrRows{ErrValue: encodeErr}, encodeErr
+	}
+
+	r.record(it)
+	return it.rows()
+}
+
+// replayRowColumn names QueryRow result columns, whose names pgx.Row does not
+// expose; it is the name Postgres gives unnamed columns.
+const replayRowColumn = "?column?"
+
+// recordQueryRow calls queryRow and returns a row that records the
+// interaction when scanned. The values are read as the driver returns them
+// and then scanned from the recorded result, so dest sees exactly what replay
+// will produce.
+func (r *ReplayDB) recordQueryRow(ctx context.Context, queryRow func(context.Context, string, ...any) pgx.Row, sql string, args []any) pgx.Row {
+	normArgs, err := normalizeReplayArgs(args)
+	if err != nil {
+		return &ErrRow{Err: err}
+	}
+	it := replayInteraction{Method: "QueryRow", SQL: normalizeReplaySQL(sql), Args: normArgs}
+	return &recordRow{r: r, row: queryRow(ctx, sql, args...), it: it}
+}
+
+type recordRow struct {
+	r   *ReplayDB
+	row pgx.Row
+	it  replayInteraction
+}
+
+func (rr *recordRow) Scan(dest ...any) error {
+	it := rr.it
+	values := make([]any, len(dest))
+	ptrs := make([]any, len(dest))
+	for i := range values {
+		ptrs[i] = &values[i]
+		it.Columns = append(it.Columns, replayRowColumn)
+	}
+
+	switch err := rr.row.Scan(ptrs...); {
+	case errors.Is(err, pgx.ErrNoRows):
+		// An empty result replays as pgx.ErrNoRows.
+	case err != nil:
+		it.Columns = nil
+		it.Error = encodeReplayError(err)
+		rr.r.record(it)
+		return err
+	default:
+		row := make([]replayValue, len(values))
+		for i, v := range values {
+			var encErr error
+			if row[i], encErr = encodeReplayValue(v); encErr != nil {
+				return encErr
+			}
+		}
+		it.Rows = [][]replayValue{row}
+	}
+
+	rr.r.record(it)
+	rows, err := it.rows()
+	if err != nil {
+		return err
+	}
+	return (&rowsRow{rows: rows}).Scan(dest...)
+}
+
+func (r *ReplayDB) replayQuery(method, sql string, args []any) (pgx.Rows, error) {
+	it, err := r.replay(method, sql, args)
+	if err != nil {
+		return &ErrRows{ErrValue: err}, err
+	}
+	if it.Error != nil {
+		err := it.Error.err()
+		return &ErrRows{ErrValue: err}, err
+	}
+	return it.rows()
+}
+
+// rows rebuilds the recorded result set as fake rows.
+func (it replayInteraction) rows() (pgx.Rows, error) {
+	b := NewRows(it.Columns)
+	if len(it.Types) == len(it.Columns) {
+		b.WithColumnTypes(it.Types...)
+	}
+	for _, row := range it.Rows {
+		values := make([]any, len(row))
+		for i, v := range row {
+			decoded, err := v.decode()
+			if err != nil {
+				return &ErrRows{ErrValue: err}, err
+			}
+			values[i] = decoded
+		}
+		b.AddRow(values...)
+	}
+	if it.RowsError != nil {
+		b.WithRowError(len(it.Rows), it.RowsError.err())
+	}
+	if it.CommandTag != "" {
+		b.WithCommandTag(pgconn.NewCommandTag(it.CommandTag))
+	}
+	return b.Build(), nil
+}
+
+func (r *ReplayDB) recordBegin(tx pgx.Tx, err error) (pgx.Tx, error) {
+	r.record(replayInteraction{Method: "Begin", Error: encodeReplayError(err)})
+	if err != nil {
+		return nil, err
+	}
+	return &recordTx{Tx: tx, r: r}, nil
+}
+
+func (r *ReplayDB) replayBegin() (pgx.Tx, error) {
+	it, err := r.replay("Begin", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if it.Error != nil {
+		return nil, it.Error.err()
+	}
+	return &replayTx{FakeTx: &FakeTx{DB: r}, r: r}, nil
+}
+
+func (r *ReplayDB) recordTxEnd(method string, err error) error {
+	r.record(replayInteraction{Method: method, Error: encodeReplayError(err)})
+	return err
+}
+
+func (r *ReplayDB) replayTxEnd(method string) error {
+	it, err := r.replay(method, "", nil)
+	if err != nil {
+		return err
+	}
+	if it.Error != nil {
+		return it.Error.err()
+	}
+	return nil
+}
+
+// recordTx records statements and transaction control on a real transaction.
+type recordTx struct {
+	pgx.Tx
+	r *ReplayDB
+}
+
+func (t *recordTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return t.r.recordExec(ctx, t.Tx.Exec, sql, args)
+}
+
+func (t *recordTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return t.r.recordQuery(ctx, "Query", t.Tx.Query, sql, args)
+}
+
+func (t *recordTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return t.r.recordQueryRow(ctx, t.Tx.QueryRow, sql, args)
+}
+
+func (t *recordTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := t.Tx.Begin(ctx)
+	return t.r.recordBegin(tx, err)
+}
+
+func (t *recordTx) Commit(ctx context.Context) error {
+	err := t.Tx.Commit(ctx)
+	if errors.Is(err, pgx.ErrTxClosed) {
+		return err
+	}
+	return t.r.recordTxEnd("Commit", err)
+}
+
+func (t *recordTx) Rollback(ctx context.Context) error {
+	err := t.Tx.Rollback(ctx)
+	if errors.Is(err, pgx.ErrTxClosed) {
+		return err
+	}
+	return t.r.recordTxEnd("Rollback", err)
+}
+
+// replayTx serves statements from the golden file through FakeTx and replays
+// transaction control.
+type replayTx struct {
+	*FakeTx
+	r *ReplayDB
+}
+
+func (t *replayTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if t.isClosed() {
+		return nil, pgx.ErrTxClosed
+	}
+	return t.r.replayBegin()
+}
+
+func (t *replayTx) Commit(ctx context.Context) error {
+	if err := t.FakeTx.Commit(ctx); err != nil {
+		return err
+	}
+	return t.r.replayTxEnd("Commit")
+}
+
+func (t *replayTx) Rollback(ctx context.Context) error {
+	if err := t.FakeTx.Rollback(ctx); err != nil {
+		return err
+	}
+	return t.r.replayTxEnd("Rollback")
+}
+
+func normalizeReplaySQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+// normalizeReplayArgs converts args to a canonical JSON array so that
+// equivalent values (int vs int64, pgtype wrappers vs plain values) match.
+func normalizeReplayArgs(args []any) (json.RawMessage, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	norm := make([]any, len(args))
+	for i, a := range args {
+		if v, ok := a.(driver.Valuer); ok {
+			val, err := v.Value()
+			if err != nil {
+				return nil, fmt.Errorf("neon.ReplayDB: normalize arg %d: %w", i, err)
+			}
+			a = val
+		}
+		switch v := a.(type) {
+		case time.Time:
+			a = v.UTC().Format(time.RFC3339Nano)
+		case []byte:
+			a = base64.StdEncoding.EncodeToString(v)
+		default:
+			if a != nil && isUUIDValue(reflect.ValueOf(a)) {
+				a = formatUUID(reflect.ValueOf(a))
+			}
+		}
+		norm[i] = a
+	}
+	data, err := json.Marshal(norm)
+	if err != nil {
+		return nil, fmt.Errorf("neon.ReplayDB: normalize args: %w", err)
+	}
+	return data, nil
+}
+
+func encodeReplayError(err error) *replayError {
+	if err == nil {
+		return nil
+	}
+	e := &replayError{Message: redactDSNs(err.Error())}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		e.Code = pgErr.Code
+		e.Message = redactDSNs(pgErr.Message)
+	}
+	return e
+}
+
+func (e *replayError) err() error {
+	if e.Code != "" {
+		return &pgconn.PgError{Severity: "ERROR", Code: e.Code, Message: e.Message}
+	}
+	if e.Message == pgx.ErrNoRows.Error() {
+		return pgx.ErrNoRows
+	}
+	return errors.New(e.Message)
+}
+
+func encodeReplayValue(v any) (replayValue, error) {
+	var typ string
+	var raw any = v
+	switch x := v.(type) {
+	case nil:
+		return replayValue{Type: "null"}, nil
+	case string:
+		typ = "string"
+	case bool:
+		typ = "bool"
+	case int16:
+		typ = "int16"
+	case int32:
+		typ = "int32"
+	case int64:
+		typ = "int64"
+	case int:
+		typ, raw = "int64", int64(x)
+	case float32:
+		typ = "float32"
+	case float64:
+		typ = "float64"
+	case time.Time:
+		typ = "time"
+	case []byte:
+		typ = "bytes"
+	case [16]byte:
+		typ, raw = "uuid", formatUUID(reflect.ValueOf(x))
+	case pgtype.Numeric:
+		typ = "numeric"
+		text, err := x.MarshalJSON()
+		if err != nil {
+			return replayValue{}, fmt.Errorf("neon.ReplayDB: encode numeric: %w", err)
+		}
+		raw = string(text)
+	case map[string]any:
+		typ = "json"
+	case []any:
+		// Elements are encoded one by one so an int64 element does not
+		// replay as a JSON float64.
+		elems := make([]replayValue, len(x))
+		for i, e := range x {
+			ev, err := encodeReplayValue(e)
+			if err != nil {
+				return replayValue{}, err
+			}
+			elems[i] = ev
+		}
+		typ, raw = "array", elems
+	default:
+		return replayValue{}, fmt.Errorf("neon.ReplayDB: cannot record result value of type %T", v)
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return replayValue{}, fmt.Errorf("neon.ReplayDB: encode %T value: %w", v, err)
+	}
+	return replayValue{Type: typ, Value: data}, nil
+}
+
+func (v replayValue) decode() (any, error) {
+	var err error
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "string":
+		var s string
+		err = json.Unmarshal(v.Value, &s)
+		return s, err
+	case "bool":
+		var b bool
+		err = json.Unmarshal(v.Value, &b)
+		return b, err
+	case "int16":
+		var n int16
+		err = json.Unmarshal(v.Value, &n)
+		return n, err
+	case "int32":
+		var n int32
+		err = json.Unmarshal(v.Value, &n)
+		return n, err
+	case "int64":
+		var n int64
+		err = json.Unmarshal(v.Value, &n)
+		return n, err
+	case "float32":
+		var f float32
+		err = json.Unmarshal(v.Value, &f)
+		return f, err
+	case "float64":
+		var f float64
+		err = json.Unmarshal(v.Value, &f)
+		return f, err
+	case "time":
+		var t time.Time
+		err = json.Unmarshal(v.Value, &t)
+		return t, err
+	case "bytes":
+		var b []byte
+		err = json.Unmarshal(v.Value, &b)
+		return b, err
+	case "uuid":
+		var s string
+		if err = json.Unmarshal(v.Value, &s); err != nil {
+			return nil, err
+		}
+		return parseUUID(s)
+	case "numeric":
+		var s string
+		if err = json.Unmarshal(v.Value, &s); err != nil {
+			return nil, err
+		}
+		var n pgtype.Numeric
+		err = n.UnmarshalJSON([]byte(s))
+		return n, err
+	case "json":
+		var a any
+		err = json.Unmarshal(v.Value, &a)
+		return a, err
+	case "array":
+		var elems []replayValue
+		if err = json.Unmarshal(v.Value, &elems); err != nil {
+			return nil, err
+		}
+		out := make([]any, len(elems))
+		for i, e := range elems {
+			if out[i], err = e.decode(); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("neon.ReplayDB: unknown value type %q in golden file", v.Type)
+}
@@ -0,0 +1,80 @@
+package neon
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// SQLDB returns a *sql.DB that borrows connections from the pool, for
+// libraries that require database/sql (goose, sqlx, some ORMs). Connections
+// keep the pool's configuration, including pooler-mode invariants.
+//
+// Closing the returned *sql.DB does not close the Pool.
+func (p *Pool) SQLDB() *sql.DB {
+	return stdlib.OpenDBFromPool(p.pool)
+}
+
+// OpenSQLDB returns a *sql.DB for cfg.ConnectionString built through the same
+// validation, defaults, pooler-mode invariants, and options as Connect.
+// MaxConns, MaxConnLifetime, and MaxConnIdleTime map onto the database/sql
+// pool settings; health-check settings and MinConns do not apply.
+//
+// Like sql.Open, no connection is made; call PingContext to verify
+// connectivity.
+func OpenSQLDB(cfg Config, opts ...Option) (*sql.DB, error) {
+	pgxCfg, _, err := buildPoolConfig(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	return openSQLDB(pgxCfg), nil
+}
+
+// OpenDirectSQLDB is like OpenSQLDB but connects to the resolved direct
+// (non-pooled) URL, for migration tools and other session-level work. The
+// extended protocol and statement caches stay enabled, since no pooler sits
+// in between.
+func OpenDirectSQLDB(cfg Config, opts ...Option) (*sql.DB, error) {
+	pooled, directURL, err := buildPoolConfig(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	directCfg, err := pgxpool.ParseConfig(directURL)
+	if err != nil {
+		// Unreachable after buildPoolConfig validated directURL; keep the
+		// message free of DSN content regardless.
+		return nil, errors.New("neon: invalid DirectURL (must be a valid pgx DSN with sslmode=require or stricter)")
+	}
+	directCfg.MaxConns = pooled.MaxConns
+	directCfg.MaxConnLifetime = pooled.MaxConnLifetime
+	directCfg.MaxConnIdleTime = pooled.MaxConnIdleTime
+	directCfg.ConnConfig.ConnectTimeout = pooled.ConnConfig.ConnectTimeout
+
+	o, err := collectConnectOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	applyConnectOptions(directCfg, o)
+
+	if err := validateTLSPosture("DirectURL", directCfg.ConnConfig); err != nil {
+		return nil, err
+	}
+	return openSQLDB(directCfg), nil
+}
+
+func openSQLDB(pgxCfg *pgxpool.Config) *sql.DB {
+	var stdOpts []stdlib.OptionOpenDB
+	if pgxCfg.AfterConnect != nil {
+		stdOpts = append(stdOpts, stdlib.OptionAfterConnect(pgxCfg.AfterConnect))
+	}
+
+	db := stdlib.OpenDB(*pgxCfg.ConnConfig, stdOpts...)
+	db.SetMaxOpenConns(int(pgxCfg.MaxConns))
+	db.SetMaxIdleConns(int(pgxCfg.MaxConns))
+	db.SetConnMaxLifetime(pgxCfg.MaxConnLifetime)
+	db.SetConnMaxIdleTime(pgxCfg.MaxConnIdleTime)
+	return db
+}
@@ -2,11 +2,22 @@ package neon
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 // ErrNotMocked is returned when a TestDB method is called without a
@@ -14,6 +25,10 @@ import (
 var ErrNotMocked = errors.New("neon.TestDB: method not mocked — set the corresponding Func field")
 
 // TestDB is a mock DB implementation for unit tests.
+//
+// Every method call is recorded (method, SQL, args, timestamp) whether or not
+// the corresponding Func field is set; see Calls and the Assert* helpers.
+// TestDB is safe for concurrent use as long as the Func fields are.
 type TestDB struct {
 	ExecFunc     func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
 	QueryFunc    func(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
@@ -22,11 +37,68 @@ type TestDB struct {
 	BeginTxFunc  func(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
 	PingFunc     func(ctx context.Context) error
 	CloseFunc    func()
+
+	// CopyFromFunc and SendBatchFunc back the DBTX methods. When nil,
+	// CopyFrom returns ErrNotMocked and SendBatch returns BatchResults whose
+	// every method fails with ErrNotMocked. See CopyRecorder and
+	// NewBatchResults for ready-made fakes.
+	CopyFromFunc  func(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	SendBatchFunc func(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var (
+	_ DB   = (*TestDB)(nil)
+	_ DBTX = (*TestDB)(nil)
+)
+
+// Call is a single recorded TestDB method invocation.
+type Call struct {
+	// Method is the method name: "Exec", "Query", "QueryRow", "Begin",
+	// "BeginTx", "Ping", "Close", "CopyFrom", or "SendBatch".
+	Method string
+
+	// SQL and Args are set for Exec, Query, and QueryRow. For CopyFrom, SQL
+	// is the sanitized table name; for SendBatch, it is the queued queries
+	// joined by "; ".
+	SQL  string
+	Args []any
+
+	// Mocked reports whether the corresponding Func field handled the call.
+	Mocked bool
+
+	Time time.Time
+}
+
+func (t *TestDB) record(method, sql string, args []any, mocked bool) {
+	var copied []any
+	if len(args) > 0 {
+		copied = append([]any(nil), args...)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = append(t.calls, Call{Method: method, SQL: sql, Args: copied, Mocked: mocked, Time: time.Now()})
+}
+
+// Calls returns a snapshot of all recorded calls in invocation order.
+func (t *TestDB) Calls() []Call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Call(nil), t.calls...)
 }
 
-var _ DB = (*TestDB)(nil)
+// ResetCalls discards all recorded calls.
+func (t *TestDB) ResetCalls() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = nil
+}
 
 func (t *TestDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	t.record("Exec", sql, args, t.ExecFunc != nil)
 	if t.ExecFunc != nil {
 		return t.ExecFunc(ctx, sql, args...)
 	}
@@ -34,6 +106,7 @@ func (t *TestDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.Comm
 }
 
 func (t *TestDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	t.record("Query", sql, args, t.QueryFunc != nil)
 	if t.QueryFunc != nil {
 		return t.QueryFunc(ctx, sql, args...)
 	}
@@ -41,6 +114,7 @@ func (t *TestDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows,
 }
 
 func (t *TestDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	t.record("QueryRow", sql, args, t.QueryRowFunc != nil)
 	if t.QueryRowFunc != nil {
 		return t.QueryRowFunc(ctx, sql, args...)
 	}
@@ -48,6 +122,7 @@ func (t *TestDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 }
 
 func (t *TestDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	t.record("Begin", "", nil, t.BeginFunc != nil)
 	if t.BeginFunc != nil {
 		return t.BeginFunc(ctx)
 	}
@@ -55,6 +130,7 @@ func (t *TestDB) Begin(ctx context.Context) (pgx.Tx, error) {
 }
 
 func (t *TestDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	t.record("BeginTx", "", nil, t.BeginTxFunc != nil)
 	if t.BeginTxFunc != nil {
 		return t.BeginTxFunc(ctx, txOptions)
 	}
@@ -62,6 +138,7 @@ func (t *TestDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx,
 }
 
 func (t *TestDB) Ping(ctx context.Context) error {
+	t.record("Ping", "", nil, t.PingFunc != nil)
 	if t.PingFunc != nil {
 		return t.PingFunc(ctx)
 	}
@@ -69,11 +146,108 @@ func (t *TestDB) Ping(ctx context.Context) error {
 }
 
 func (t *TestDB) Close() {
+	t.record("Close", "", nil, t.CloseFunc != nil)
 	if t.CloseFunc != nil {
 		t.CloseFunc()
 	}
 }
 
+func (t *TestDB) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	t.record("CopyFrom", tableName.Sanitize(), nil, t.CopyFromFunc != nil)
+	if t.CopyFromFunc != nil {
+		return t.CopyFromFunc(ctx, tableName, columnNames, rowSrc)
+	}
+	return 0, ErrNotMocked
+}
+
+func (t *TestDB) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	var queries []string
+	if b != nil {
+		for _, q := range b.QueuedQueries {
+			queries = append(queries, q.SQL)
+		}
+	}
+	t.record("SendBatch", strings.Join(queries, "; "), nil, t.SendBatchFunc != nil)
+	if t.SendBatchFunc != nil {
+		return t.SendBatchFunc(ctx, b)
+	}
+	return &errBatchResults{err: ErrNotMocked}
+}
+
+// AssertCalled reports a test error unless method was called at least once.
+func (t *TestDB) AssertCalled(tb testing.TB, method string) {
+	tb.Helper()
+	if t.countCalls(method) == 0 {
+		tb.Errorf("neon.TestDB: expected %s to be called; calls: %s", method, t.describeCalls())
+	}
+}
+
+// AssertCallCount reports a test error unless method was called exactly want
+// times.
+func (t *TestDB) AssertCallCount(tb testing.TB, method string, want int) {
+	tb.Helper()
+	if got := t.countCalls(method); got != want {
+		tb.Errorf("neon.TestDB: %s called %d time(s), want %d; calls: %s", method, got, want, t.describeCalls())
+	}
+}
+
+// AssertSQLContains reports a test error unless at least one recorded
+// Exec, Query, or QueryRow call has SQL containing substr.
+func (t *TestDB) AssertSQLContains(tb testing.TB, substr string) {
+	tb.Helper()
+	for _, c := range t.Calls() {
+		if c.SQL != "" && strings.Contains(c.SQL, substr) {
+			return
+		}
+	}
+	tb.Errorf("neon.TestDB: no recorded SQL contains %q; calls: %s", substr, t.describeCalls())
+}
+
+// AssertNoUnexpectedCalls reports a test error for every recorded call to a
+// method whose Func field was not set and which therefore returned
+// ErrNotMocked. Ping and Close have safe defaults and are never unexpected.
+func (t *TestDB) AssertNoUnexpectedCalls(tb testing.TB) {
+	tb.Helper()
+	for _, c := range t.Calls() {
+		if c.Mocked || c.Method == "Ping" || c.Method == "Close" {
+			continue
+		}
+		if c.SQL != "" {
+			tb.Errorf("neon.TestDB: unexpected %s call (not mocked): %s", c.Method, c.SQL)
+		} else {
+			tb.Errorf("neon.TestDB: unexpected %s call (not mocked)", c.Method)
+		}
+	}
+}
+
+func (t *TestDB) countCalls(method string) int {
+	n := 0
+	for _, c := range t.Calls() {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// describeCalls summarizes recorded calls for assertion messages. Args are
+// omitted because they may contain sensitive values.
+func (t *TestDB) describeCalls() string {
+	calls := t.Calls()
+	if len(calls) == 0 {
+		return "(none)"
+	}
+	parts := make([]string, len(calls))
+	for i, c := range calls {
+		if c.SQL != "" {
+			parts[i] = fmt.Sprintf("%s(%q)", c.Method, c.SQL)
+		} else {
+			parts[i] = c.Method
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
 // ErrRow implements pgx.Row. Its Scan always returns Err.
 type ErrRow struct {
 	Err error
@@ -93,6 +267,12 @@ type valueRow struct {
 }
 
 func (r *valueRow) Scan(dest ...any) error {
+	if len(dest) == 1 {
+		if rs, ok := dest[0].(pgx.RowScanner); ok {
+			rows := &fakeRows{columns: make([]string, len(r.values)), data: [][]any{r.values}, idx: 0}
+			return rs.ScanRow(rows)
+		}
+	}
 	if len(dest) != len(r.values) {
 		return fmt.Errorf("neon.valueRow: scan dest count %d != column count %d", len(dest), len(r.values))
 	}
@@ -129,9 +309,28 @@ func (r *ErrRows) Scan(dest ...any) error {
 }
 
 // RowsBuilder builds pgx.Rows backed by in-memory rows.
+//
+// Built rows work with pgx's generic helpers (pgx.CollectRows,
+// RowToStructByName, RowToStructByPos, RowToMap, ForEachRow, and so on):
+// FieldDescriptions report each column's type OID and format, and RawValues
+// returns the current row encoded as a real server would send it. Column
+// types are inferred from the first non-NULL value in each column unless
+// declared with WithColumnTypes.
+//
+// WithRowError and WithCommandTag simulate a failure part-way through the
+// result set and the command tag reported at the end; AssertClosed verifies
+// that code under test closed every cursor it was handed.
 type RowsBuilder struct {
 	columns []string
+	types   []uint32
 	rows    [][]any
+
+	rowErr      error
+	rowErrAfter int
+	tag         pgconn.CommandTag
+
+	mu    sync.Mutex
+	built []*fakeRows
 }
 
 // NewRows creates a new RowsBuilder.
@@ -148,25 +347,138 @@ func (b *RowsBuilder) AddRow(values ...any) *RowsBuilder {
 	return b
 }
 
+// WithColumnTypes declares the PostgreSQL type OID of each column, for
+// example pgtype.Int8OID or pgtype.TextOID. It panics on arity mismatch.
+func (b *RowsBuilder) WithColumnTypes(oids ...uint32) *RowsBuilder {
+	if len(oids) != len(b.columns) {
+		panic("neon.RowsBuilder: column type count mismatch")
+	}
+	b.types = append([]uint32(nil), oids...)
+	return b
+}
+
+// WithRowError makes built cursors fail after returning n rows: the next call
+// to Next returns false and Err returns err. If n is at least the number of
+// rows, the error surfaces after the last row. Closing a cursor early also
+// reports err, as pgx does when draining the remaining result.
+func (b *RowsBuilder) WithRowError(n int, err error) *RowsBuilder {
+	if n < 0 {
+		n = 0
+	}
+	b.rowErr = err
+	b.rowErrAfter = n
+	return b
+}
+
+// WithCommandTag sets the command tag reported by built cursors once they
+// have completed without error.
+func (b *RowsBuilder) WithCommandTag(tag pgconn.CommandTag) *RowsBuilder {
+	b.tag = tag
+	return b
+}
+
 // Build returns a pgx.Rows cursor for the builder data.
 func (b *RowsBuilder) Build() pgx.Rows {
-	return &fakeRows{
-		columns: b.columns,
-		data:    b.rows,
-		idx:     -1,
+	r := &fakeRows{
+		columns:     b.columns,
+		types:       b.types,
+		data:        b.rows,
+		idx:         -1,
+		rowErr:      b.rowErr,
+		rowErrAfter: min(b.rowErrAfter, len(b.rows)),
+		tag:         b.tag,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.built = append(b.built, r)
+	return r
+}
+
+// AssertClosed reports a test error if any cursor returned by Build has not
+// had Close called on it. Cursors that were only iterated to the end do not
+// count as closed.
+func (b *RowsBuilder) AssertClosed(tb testing.TB) {
+	tb.Helper()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	open := 0
+	for _, r := range b.built {
+		if !r.closeCalled.Load() {
+			open++
+		}
+	}
+	if open > 0 {
+		tb.Errorf("neon.RowsBuilder: %d of %d built rows were not closed", open, len(b.built))
 	}
 }
 
 type fakeRows struct {
 	columns []string
+	types   []uint32
 	data    [][]any
 	idx     int
 	closed  bool
 	scanErr error
+
+	rowErr      error
+	rowErrAfter int
+	tag         pgconn.CommandTag
+	closeCalled atomic.Bool
+
+	typeMap *pgtype.Map
+}
+
+func (r *fakeRows) pgTypeMap() *pgtype.Map {
+	if r.typeMap == nil {
+		r.typeMap = pgtype.NewMap()
+	}
+	return r.typeMap
+}
+
+// columnOIDs returns the declared column types, inferring undeclared ones from
+// the first non-NULL value in each column. All-NULL columns are reported as
+// text.
+func (r *fakeRows) columnOIDs() []uint32 {
+	if r.types != nil {
+		return r.types
+	}
+
+	m := r.pgTypeMap()
+	oids := make([]uint32, len(r.columns))
+	for i := range r.columns {
+		oids[i] = pgtype.TextOID
+		for _, row := range r.data {
+			if row[i] == nil {
+				continue
+			}
+			if typ, ok := m.TypeForValue(row[i]); ok {
+				oids[i] = typ.OID
+			}
+			break
+		}
+	}
+	r.types = oids
+	return oids
 }
 
 func (r *fakeRows) Close() {
+	r.closeCalled.Store(true)
+	if !r.closed && r.rowErr != nil {
+		r.fail()
+	}
+	r.closed = true
+}
+
+// fail ends iteration with the configured mid-result error.
+func (r *fakeRows) fail() {
 	r.closed = true
+	r.idx = len(r.data)
+	if r.scanErr == nil {
+		r.scanErr = r.rowErr
+	}
 }
 
 func (r *fakeRows) Err() error {
@@ -174,21 +486,50 @@ func (r *fakeRows) Err() error {
 }
 
 func (r *fakeRows) CommandTag() pgconn.CommandTag {
-	return pgconn.CommandTag{}
+	if !r.closed || r.scanErr != nil {
+		return pgconn.CommandTag{}
+	}
+	return r.tag
 }
 
 func (r *fakeRows) Conn() *pgx.Conn {
 	return nil
 }
 
+// RawValues encodes the current row using each column's type and format.
+// NULLs, and values that cannot be encoded as the column type, are nil.
 func (r *fakeRows) RawValues() [][]byte {
-	return nil
+	if r.idx < 0 || r.idx >= len(r.data) {
+		return nil
+	}
+
+	m := r.pgTypeMap()
+	oids := r.columnOIDs()
+	raw := make([][]byte, len(r.data[r.idx]))
+	for i, val := range r.data[r.idx] {
+		if val == nil {
+			continue
+		}
+		buf, err := m.Encode(oids[i], m.FormatCodeForOID(oids[i]), val, nil)
+		if err == nil {
+			raw[i] = buf
+		}
+	}
+	return raw
 }
 
 func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription {
+	m := r.pgTypeMap()
+	oids := r.columnOIDs()
 	fields := make([]pgconn.FieldDescription, len(r.columns))
 	for i, col := range r.columns {
-		fields[i] = pgconn.FieldDescription{Name: col}
+		fields[i] = pgconn.FieldDescription{
+			Name:         col,
+			DataTypeOID:  oids[i],
+			DataTypeSize: -1,
+			TypeModifier: -1,
+			Format:       m.FormatCodeForOID(oids[i]),
+		}
 	}
 	return fields
 }
@@ -199,6 +540,10 @@ func (r *fakeRows) Next() bool {
 	}
 
 	r.idx++
+	if r.rowErr != nil && r.idx >= r.rowErrAfter {
+		r.fail()
+		return false
+	}
 	if r.idx >= len(r.data) {
 		r.closed = true
 		return false
@@ -211,6 +556,16 @@ func (r *fakeRows) Scan(dest ...any) error {
 		return pgx.ErrNoRows
 	}
 
+	if len(dest) == 1 {
+		if rs, ok := dest[0].(pgx.RowScanner); ok {
+			if err := rs.ScanRow(r); err != nil {
+				r.scanErr = err
+				return err
+			}
+			return nil
+		}
+	}
+
 	row := r.data[r.idx]
 	if len(dest) != len(row) {
 		err := fmt.Errorf("neon.fakeRows: scan dest count %d != column count %d", len(dest), len(row))
@@ -235,43 +590,275 @@ func (r *fakeRows) Values() ([]any, error) {
 	return r.data[r.idx], nil
 }
 
+// assignScanValue copies a fake column value into a Scan destination,
+// following pgx's assignment rules closely enough for unit tests:
+//
+//   - A nil destination skips the column.
+//   - *any receives the value as-is.
+//   - A value assignable to the destination type is copied directly.
+//   - driver.Valuer values (pgtype.*, sql.Null*) are unwrapped first.
+//   - NULL (nil) scans into *any, pointer destinations (**T), and sql.Scanner
+//     implementations; any other destination is an error.
+//   - sql.Scanner destinations (sql.Null*, pgtype.*, uuid types) receive the
+//     value normalized to a database/sql driver type.
+//   - Integers convert into signed and unsigned integer destinations of any
+//     width with range checks, as in pgx, and into floats; float64 narrows
+//     into float32 only when it fits.
+//   - Strings scan into named string types and []byte; 16-byte arrays (UUIDs)
+//     scan into strings in canonical form.
 func assignScanValue(prefix string, idx int, dest any, val any) error {
-	switch d := dest.(type) {
-	case *string:
-		v, ok := val.(string)
-		if !ok {
-			return fmt.Errorf("%s: expected string at column %d, got %T", prefix, idx, val)
+	if dest == nil {
+		return nil
+	}
+	if d, ok := dest.(*any); ok {
+		*d = val
+		return nil
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return fmt.Errorf("%s: unsupported scan target type %T at column %d", prefix, dest, idx)
+	}
+	elem := dv.Elem()
+
+	if val != nil {
+		if vv := reflect.ValueOf(val); vv.Type().AssignableTo(elem.Type()) {
+			if b, ok := val.([]byte); ok {
+				val = append([]byte(nil), b...)
+			}
+			elem.Set(reflect.ValueOf(val))
+			return nil
 		}
-		*d = v
-	case *int:
-		v, ok := val.(int)
-		if !ok {
-			return fmt.Errorf("%s: expected int at column %d, got %T", prefix, idx, val)
+		if valuer, ok := val.(driver.Valuer); ok {
+			v, err := valuer.Value()
+			if err != nil {
+				return fmt.Errorf("%s: column %d: %w", prefix, idx, err)
+			}
+			val = v
 		}
-		*d = v
-	case *int64:
-		v, ok := val.(int64)
-		if !ok {
-			return fmt.Errorf("%s: expected int64 at column %d, got %T", prefix, idx, val)
+	}
+
+	if scanner, ok := dest.(sql.Scanner); ok {
+		if err := scanner.Scan(normalizeScanValue(val)); err != nil {
+			return fmt.Errorf("%s: scan into %T at column %d: %w", prefix, dest, idx, err)
 		}
-		*d = v
-	case *bool:
-		v, ok := val.(bool)
-		if !ok {
-			return fmt.Errorf("%s: expected bool at column %d, got %T", prefix, idx, val)
+		return nil
+	}
+
+	if elem.Kind() == reflect.Pointer {
+		if val == nil {
+			elem.Set(reflect.Zero(elem.Type()))
+			return nil
 		}
-		*d = v
-	case *float64:
-		v, ok := val.(float64)
-		if !ok {
-			return fmt.Errorf("%s: expected float64 at column %d, got %T", prefix, idx, val)
+		target := reflect.New(elem.Type().Elem())
+		if err := assignScanValue(prefix, idx, target.Interface(), val); err != nil {
+			return err
 		}
-		*d = v
-	case *any:
-		*d = val
-	default:
+		elem.Set(target)
+		return nil
+	}
+
+	if !scanKindSupported(elem.Type()) {
 		return fmt.Errorf("%s: unsupported scan target type %T at column %d", prefix, dest, idx)
 	}
+	if val == nil {
+		return fmt.Errorf("%s: cannot scan NULL into %T at column %d", prefix, dest, idx)
+	}
+
+	vv := reflect.ValueOf(val)
+	mismatch := fmt.Errorf("%s: expected %s at column %d, got %T", prefix, elem.Type(), idx, val)
+
+	switch {
+	case elem.Type() == timeType:
+		return mismatch
+
+	case isIntKind(elem.Kind()):
+		n, ok := intValue(vv)
+		if !ok {
+			return mismatch
+		}
+		if elem.OverflowInt(n) {
+			return fmt.Errorf("%s: value %d out of range for %s at column %d", prefix, n, elem.Type(), idx)
+		}
+		elem.SetInt(n)
+
+	case isUintKind(elem.Kind()):
+		var u uint64
+		switch {
+		case isUintKind(vv.Kind()):
+			u = vv.Uint()
+		case isIntKind(vv.Kind()):
+			n := vv.Int()
+			if n < 0 {
+				return fmt.Errorf("%s: value %d out of range for %s at column %d", prefix, n, elem.Type(), idx)
+			}
+			u = uint64(n)
+		default:
+			return mismatch
+		}
+		if elem.OverflowUint(u) {
+			return fmt.Errorf("%s: value %d out of range for %s at column %d", prefix, u, elem.Type(), idx)
+		}
+		elem.SetUint(u)
+
+	case elem.Kind() == reflect.Float32 || elem.Kind() == reflect.Float64:
+		var f float64
+		switch {
+		case vv.Kind() == reflect.Float32 || vv.Kind() == reflect.Float64:
+			f = vv.Float()
+		default:
+			n, ok := intValue(vv)
+			if !ok {
+				return mismatch
+			}
+			f = float64(n)
+		}
+		if elem.OverflowFloat(f) {
+			return fmt.Errorf("%s: value %v out of range for %s at column %d", prefix, f, elem.Type(), idx)
+		}
+		elem.SetFloat(f)
+
+	case elem.Kind() == reflect.Bool:
+		if vv.Kind() != reflect.Bool {
+			return mismatch
+		}
+		elem.SetBool(vv.Bool())
+
+	case elem.Kind() == reflect.String:
+		switch {
+		case vv.Kind() == reflect.String:
+			elem.SetString(vv.String())
+		case isUUIDValue(vv):
+			elem.SetString(formatUUID(vv))
+		default:
+			return mismatch
+		}
+
+	case elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() == reflect.Uint8:
+		switch {
+		case vv.Kind() == reflect.String:
+			elem.SetBytes([]byte(vv.String()))
+		case vv.Kind() == reflect.Slice && vv.Type().Elem().Kind() == reflect.Uint8:
+			elem.SetBytes(append([]byte(nil), vv.Bytes()...))
+		default:
+			return mismatch
+		}
+
+	case isUUIDType(elem.Type()):
+		switch {
+		case isUUIDValue(vv):
+			reflect.Copy(elem, vv)
+		case vv.Kind() == reflect.String:
+			b, err := parseUUID(vv.String())
+			if err != nil {
+				return fmt.Errorf("%s: column %d: %w", prefix, idx, err)
+			}
+			reflect.Copy(elem, reflect.ValueOf(b[:]))
+		default:
+			return mismatch
+		}
+	}
 
 	return nil
 }
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// scanKindSupported reports whether assignScanValue knows how to convert into
+// a destination of type t (beyond direct assignment and sql.Scanner).
+func scanKindSupported(t reflect.Type) bool {
+	switch {
+	case t == timeType, isIntKind(t.Kind()), isUintKind(t.Kind()), isUUIDType(t):
+		return true
+	case t.Kind() == reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Bool, reflect.String:
+		return true
+	}
+	return false
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUintKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func intValue(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(u), true
+	}
+	return 0, false
+}
+
+func isUUIDType(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8
+}
+
+func isUUIDValue(v reflect.Value) bool {
+	return isUUIDType(v.Type())
+}
+
+func formatUUID(v reflect.Value) string {
+	var b [16]byte
+	reflect.Copy(reflect.ValueOf(b[:]), v)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func parseUUID(s string) ([16]byte, error) {
+	var b [16]byte
+	h := strings.ReplaceAll(s, "-", "")
+	if len(h) != 32 {
+		return b, fmt.Errorf("invalid UUID %q", s)
+	}
+	if _, err := hex.Decode(b[:], []byte(h)); err != nil {
+		return b, fmt.Errorf("invalid UUID %q", s)
+	}
+	return b, nil
+}
+
+// normalizeScanValue converts a fake column value into one of the
+// database/sql driver value types expected by sql.Scanner implementations.
+func normalizeScanValue(val any) any {
+	if val == nil {
+		return nil
+	}
+	switch v := val.(type) {
+	case int64, float64, bool, []byte, string, time.Time:
+		return v
+	}
+	vv := reflect.ValueOf(val)
+	switch {
+	case vv.Kind() == reflect.Float32:
+		return vv.Float()
+	case vv.Kind() == reflect.Bool:
+		return vv.Bool()
+	case vv.Kind() == reflect.String:
+		return vv.String()
+	case isUUIDValue(vv):
+		return formatUUID(vv)
+	}
+	if n, ok := intValue(vv); ok {
+		return n
+	}
+	return val
+}
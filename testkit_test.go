@@ -2,12 +2,18 @@ package neon
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"math"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func TestTestDB_UnsetMethodsReturnErrNotMocked(t *testing.T) {
@@ -262,7 +268,7 @@ func TestNewRow_ScanTypeMismatch(t *testing.T) {
 func TestNewRow_ScanUnsupportedDestType(t *testing.T) {
 	t.Parallel()
 
-	var got uint64
+	var got complex128
 	err := NewRow(1).Scan(&got)
 	if err == nil {
 		t.Fatal("expected error")
@@ -473,7 +479,7 @@ func TestRowsBuilder_ScanTypeMismatchAndUnsupportedDest(t *testing.T) {
 		{
 			name: "unsupported-dest",
 			dest: func() []any {
-				v := uint64(0)
+				v := complex128(0)
 				return []any{&v}
 			},
 			wantMsg: "unsupported scan target type",
@@ -500,3 +506,451 @@ func TestRowsBuilder_ScanTypeMismatchAndUnsupportedDest(t *testing.T) {
 		})
 	}
 }
+
+func TestTestDB_RecordsCalls(t *testing.T) {
+	t.Parallel()
+
+	db := &TestDB{
+		ExecFunc: func(context.Context, string, ...any) (pgconn.CommandTag, error) {
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		},
+	}
+
+	before := time.Now()
+	args := []any{"Demo", 7}
+	_, _ = db.Exec(context.Background(), "UPDATE projects SET name = $1 WHERE id = $2", args...)
+	args[0] = "mutated"
+	_, _ = db.Query(context.Background(), "SELECT 1")
+	_ = db.Ping(context.Background())
+	db.Close()
+
+	calls := db.Calls()
+	if len(calls) != 4 {
+		t.Fatalf("calls=%d, want 4", len(calls))
+	}
+	exec := calls[0]
+	if exec.Method != "Exec" || exec.SQL != "UPDATE projects SET name = $1 WHERE id = $2" || !exec.Mocked {
+		t.Fatalf("unexpected exec call: %+v", exec)
+	}
+	if len(exec.Args) != 2 || exec.Args[0] != "Demo" || exec.Args[1] != 7 {
+		t.Fatalf("exec args=%v, want copied [Demo 7]", exec.Args)
+	}
+	if exec.Time.Before(before) {
+		t.Fatalf("exec time=%v before test start %v", exec.Time, before)
+	}
+	if calls[1].Method != "Query" || calls[1].Mocked {
+		t.Fatalf("unexpected query call: %+v", calls[1])
+	}
+	if calls[2].Method != "Ping" || calls[3].Method != "Close" {
+		t.Fatalf("unexpected trailing calls: %+v", calls[2:])
+	}
+
+	db.ResetCalls()
+	if got := len(db.Calls()); got != 0 {
+		t.Fatalf("calls after reset=%d, want 0", got)
+	}
+}
+
+func TestTestDB_RecordsConcurrentCalls(t *testing.T) {
+	t.Parallel()
+
+	db := &TestDB{
+		QueryRowFunc: func(context.Context, string, ...any) pgx.Row { return NewRow(1) },
+	}
+
+	const workers = 32
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var n int
+			_ = db.QueryRow(context.Background(), "SELECT $1::int", i).Scan(&n)
+		}(i)
+	}
+	wg.Wait()
+
+	db.AssertCallCount(t, "QueryRow", workers)
+}
+
+func TestTestDB_AssertionsPass(t *testing.T) {
+	t.Parallel()
+
+	db := &TestDB{
+		QueryRowFunc: func(context.Context, string, ...any) pgx.Row { return NewRow(42, "My Project") },
+	}
+	var id int
+	var name string
+	_ = db.QueryRow(context.Background(), "SELECT id, name FROM projects WHERE id = $1", 42).Scan(&id, &name)
+	_ = db.Ping(context.Background())
+
+	db.AssertCalled(t, "QueryRow")
+	db.AssertCallCount(t, "QueryRow", 1)
+	db.AssertCallCount(t, "Exec", 0)
+	db.AssertSQLContains(t, "WHERE id = $1")
+	db.AssertNoUnexpectedCalls(t)
+}
+
+func TestTestDB_AssertionsReportFailures(t *testing.T) {
+	t.Parallel()
+
+	db := &TestDB{}
+	_, _ = db.Exec(context.Background(), "DELETE FROM projects WHERE id = $1", 1)
+	_, _ = db.Begin(context.Background())
+
+	tb := &recordingTB{name: t.Name()}
+	db.AssertCalled(tb, "Query")
+	db.AssertCallCount(tb, "Exec", 2)
+	db.AssertSQLContains(tb, "INSERT")
+	db.AssertNoUnexpectedCalls(tb)
+
+	want := []string{
+		"expected Query to be called",
+		"Exec called 1 time(s), want 2",
+		`no recorded SQL contains "INSERT"`,
+		"unexpected Exec call (not mocked): DELETE FROM projects WHERE id = $1",
+		"unexpected Begin call (not mocked)",
+	}
+	if len(tb.errs) != len(want) {
+		t.Fatalf("errors=%q, want %d entries", tb.errs, len(want))
+	}
+	for i, w := range want {
+		if !strings.Contains(tb.errs[i], w) {
+			t.Errorf("error[%d]=%q, want containing %q", i, tb.errs[i], w)
+		}
+	}
+}
+
+type upperScanner struct{ v string }
+
+func (u *upperScanner) Scan(src any) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("upperScanner: unexpected %T", src)
+	}
+	u.v = strings.ToUpper(s)
+	return nil
+}
+
+type projectStatus string
+
+func TestNewRow_ScanRichTypes(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	id := [16]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}
+	const idText = "12345678-9abc-def0-1234-56789abcdef0"
+
+	var (
+		ts       time.Time
+		raw      []byte
+		fromText []byte
+		i32      int32
+		i16      int16
+		widened  int64
+		f32      float32
+		fromInt  float64
+		uuidStr  string
+		uuidArr  [16]byte
+		status   projectStatus
+		pgUUID   pgtype.UUID
+		pgInt    pgtype.Int4
+		pgTS     pgtype.Timestamptz
+		fromPg   int32
+		nullStr  sql.NullString
+		nullI64  sql.NullInt64
+		nullTime sql.NullTime
+		custom   upperScanner
+		ptr      *string
+		nilPtr   = new(int)
+	)
+	err := NewRow(
+		now, []byte("bin"), "txt", int32(7), 12, int32(9), float32(1.5), 3,
+		id, idText, "active", idText, int64(42), now, pgtype.Int4{Int32: 5, Valid: true},
+		"s", nil, now, "shout", "p", nil,
+	).Scan(
+		&ts, &raw, &fromText, &i32, &i16, &widened, &f32, &fromInt,
+		&uuidStr, &uuidArr, &status, &pgUUID, &pgInt, &pgTS, &fromPg,
+		&nullStr, &nullI64, &nullTime, &custom, &ptr, &nilPtr,
+	)
+	if err != nil {
+		t.Fatalf("Scan error=%v", err)
+	}
+
+	if !ts.Equal(now) || string(raw) != "bin" || string(fromText) != "txt" {
+		t.Fatalf("ts=%v raw=%q fromText=%q", ts, raw, fromText)
+	}
+	if i32 != 7 || i16 != 12 || widened != 9 || f32 != 1.5 || fromInt != 3 {
+		t.Fatalf("numbers: i32=%d i16=%d widened=%d f32=%v fromInt=%v", i32, i16, widened, f32, fromInt)
+	}
+	if uuidStr != idText || uuidArr != id || status != "active" {
+		t.Fatalf("uuidStr=%q uuidArr=%x status=%q", uuidStr, uuidArr, status)
+	}
+	if !pgUUID.Valid || pgUUID.Bytes != id || !pgInt.Valid || pgInt.Int32 != 42 || !pgTS.Valid || !pgTS.Time.Equal(now) || fromPg != 5 {
+		t.Fatalf("pgtype: uuid=%+v int=%+v ts=%+v fromPg=%d", pgUUID, pgInt, pgTS, fromPg)
+	}
+	if !nullStr.Valid || nullStr.String != "s" || nullI64.Valid || !nullTime.Valid {
+		t.Fatalf("sql.Null: str=%+v i64=%+v time=%+v", nullStr, nullI64, nullTime)
+	}
+	if custom.v != "SHOUT" {
+		t.Fatalf("custom=%q", custom.v)
+	}
+	if ptr == nil || *ptr != "p" || nilPtr != nil {
+		t.Fatalf("ptr=%v nilPtr=%v", ptr, nilPtr)
+	}
+}
+
+func TestNewRow_ScanUnsignedIntegers(t *testing.T) {
+	t.Parallel()
+
+	var (
+		u8  uint8
+		u16 uint16
+		u32 uint32
+		u64 uint64
+		u   uint
+		i64 int64
+	)
+	err := NewRow(int16(255), int32(65535), int64(1<<32-1), uint64(math.MaxUint64), 7, uint32(9)).
+		Scan(&u8, &u16, &u32, &u64, &u, &i64)
+	if err != nil {
+		t.Fatalf("Scan error=%v", err)
+	}
+	if u8 != 255 || u16 != 65535 || u32 != 1<<32-1 || u64 != math.MaxUint64 || u != 7 || i64 != 9 {
+		t.Fatalf("u8=%d u16=%d u32=%d u64=%d u=%d i64=%d", u8, u16, u32, u64, u, i64)
+	}
+}
+
+func TestNewRow_ScanRichTypeErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		val     any
+		dest    any
+		wantMsg string
+	}{
+		{name: "null-into-value", val: nil, dest: new(string), wantMsg: "cannot scan NULL into *string at column 0"},
+		{name: "int-overflow", val: int64(1 << 40), dest: new(int32), wantMsg: "value 1099511627776 out of range for int32"},
+		{name: "negative-into-uint", val: int64(-1), dest: new(uint32), wantMsg: "value -1 out of range for uint32"},
+		{name: "uint-overflow", val: int32(300), dest: new(uint8), wantMsg: "value 300 out of range for uint8"},
+		{name: "float-into-int", val: 1.5, dest: new(int64), wantMsg: "expected int64 at column 0, got float64"},
+		{name: "string-into-time", val: "2024-01-01", dest: new(time.Time), wantMsg: "expected time.Time at column 0, got string"},
+		{name: "bad-uuid", val: "not-a-uuid", dest: new([16]byte), wantMsg: `invalid UUID "not-a-uuid"`},
+		{name: "scanner-error", val: 12, dest: new(upperScanner), wantMsg: "scan into *neon.upperScanner at column 0: upperScanner: unexpected int64"},
+		{name: "non-pointer-dest", val: 1, dest: 1, wantMsg: "unsupported scan target type int"},
+		{name: "null-pointer-mismatch", val: "x", dest: new(*int), wantMsg: "expected int at column 0, got string"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := NewRow(tc.val).Scan(tc.dest)
+			if err == nil || !strings.Contains(err.Error(), tc.wantMsg) {
+				t.Fatalf("error=%v, want substring %q", err, tc.wantMsg)
+			}
+		})
+	}
+}
+
+func TestRowsBuilder_PgxCollectHelpers(t *testing.T) {
+	t.Parallel()
+
+	type project struct {
+		ID        int64
+		Name      string
+		OwnerID   *string `db:"owner_id"`
+		CreatedAt time.Time
+	}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	owner := "u1"
+	build := func() pgx.Rows {
+		return NewRows([]string{"id", "name", "owner_id", "created_at"}).
+			AddRow(int64(1), "Demo", owner, created).
+			AddRow(int64(2), "Other", nil, created).
+			Build()
+	}
+
+	byName, err := pgx.CollectRows(build(), pgx.RowToStructByName[project])
+	if err != nil {
+		t.Fatalf("RowToStructByName error=%v", err)
+	}
+	if len(byName) != 2 || byName[0].Name != "Demo" || *byName[0].OwnerID != "u1" || byName[1].OwnerID != nil || !byName[1].CreatedAt.Equal(created) {
+		t.Fatalf("byName=%+v", byName)
+	}
+
+	byPos, err := pgx.CollectRows(build(), pgx.RowToAddrOfStructByPos[project])
+	if err != nil || len(byPos) != 2 || byPos[1].ID != 2 {
+		t.Fatalf("RowToAddrOfStructByPos=%+v error=%v", byPos, err)
+	}
+
+	maps, err := pgx.CollectRows(build(), pgx.RowToMap)
+	if err != nil || len(maps) != 2 || maps[0]["name"] != "Demo" || maps[1]["owner_id"] != nil {
+		t.Fatalf("RowToMap=%v error=%v", maps, err)
+	}
+
+	one, err := pgx.CollectOneRow(NewRows([]string{"count"}).AddRow(int64(7)).Build(), pgx.RowTo[int64])
+	if err != nil || one != 7 {
+		t.Fatalf("CollectOneRow=%d error=%v", one, err)
+	}
+
+	_, err = pgx.CollectRows(build(), pgx.RowToStructByName[struct{ ID int64 }])
+	if err == nil || !strings.Contains(err.Error(), "struct doesn't have corresponding row field name") {
+		t.Fatalf("missing field error=%v", err)
+	}
+}
+
+func TestRowsBuilder_FieldDescriptionsAndRawValues(t *testing.T) {
+	t.Parallel()
+
+	rows := NewRows([]string{"id", "name", "score", "note"}).
+		AddRow(int64(42), "Demo", nil, nil).
+		AddRow(int64(43), "Other", 1.5, nil).
+		Build()
+
+	fds := rows.FieldDescriptions()
+	wantOIDs := []uint32{pgtype.Int8OID, pgtype.TextOID, pgtype.Float8OID, pgtype.TextOID}
+	for i, fd := range fds {
+		if fd.DataTypeOID != wantOIDs[i] {
+			t.Fatalf("column %s oid=%d, want %d", fd.Name, fd.DataTypeOID, wantOIDs[i])
+		}
+	}
+	if rows.RawValues() != nil {
+		t.Fatal("RawValues before Next should be nil")
+	}
+	if !rows.Next() {
+		t.Fatal("expected first row")
+	}
+
+	raw := rows.RawValues()
+	m := pgtype.NewMap()
+	var id int64
+	if err := m.Scan(fds[0].DataTypeOID, fds[0].Format, raw[0], &id); err != nil || id != 42 {
+		t.Fatalf("decoded id=%d error=%v", id, err)
+	}
+	var name string
+	if err := m.Scan(fds[1].DataTypeOID, fds[1].Format, raw[1], &name); err != nil || name != "Demo" {
+		t.Fatalf("decoded name=%q error=%v", name, err)
+	}
+	if raw[2] != nil || raw[3] != nil {
+		t.Fatalf("NULL raw values=%v", raw[2:])
+	}
+	rows.Close()
+
+	declared := NewRows([]string{"id"}).WithColumnTypes(pgtype.Int4OID).AddRow(5).Build()
+	if got := declared.FieldDescriptions()[0].DataTypeOID; got != pgtype.Int4OID {
+		t.Fatalf("declared oid=%d", got)
+	}
+	declared.Next()
+	if raw := declared.RawValues(); len(raw[0]) != 4 {
+		t.Fatalf("int4 raw=%x, want 4 bytes", raw[0])
+	}
+}
+
+func TestRowsBuilder_WithColumnTypesPanicsOnMismatch(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r != "neon.RowsBuilder: column type count mismatch" {
+			t.Fatalf("recover=%v", r)
+		}
+	}()
+	NewRows([]string{"a", "b"}).WithColumnTypes(pgtype.TextOID)
+}
+
+func TestNewRow_ScanNilDestSkipsColumn(t *testing.T) {
+	t.Parallel()
+
+	var name string
+	if err := NewRow(1, "Demo").Scan(nil, &name); err != nil || name != "Demo" {
+		t.Fatalf("name=%q error=%v", name, err)
+	}
+}
+
+func TestRowsBuilder_WithRowErrorAfterN(t *testing.T) {
+	t.Parallel()
+
+	dropped := errors.New("connection reset")
+	b := NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3).WithRowError(2, dropped).
+		WithCommandTag(pgconn.NewCommandTag("SELECT 3"))
+
+	rows := b.Build()
+	var got []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("Scan error=%v", err)
+		}
+		got = append(got, id)
+	}
+	if len(got) != 2 {
+		t.Fatalf("rows before error=%v, want 2", got)
+	}
+	if !errors.Is(rows.Err(), dropped) {
+		t.Fatalf("Err=%v, want %v", rows.Err(), dropped)
+	}
+	if tag := rows.CommandTag(); tag.String() != "" {
+		t.Fatalf("CommandTag after error=%q, want empty", tag.String())
+	}
+	if err := rows.Scan(new(int)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("Scan after error=%v", err)
+	}
+
+	if _, err := pgx.CollectRows(b.Build(), pgx.RowTo[int]); !errors.Is(err, dropped) {
+		t.Fatalf("CollectRows error=%v", err)
+	}
+}
+
+func TestRowsBuilder_WithRowErrorPastEndAndEarlyClose(t *testing.T) {
+	t.Parallel()
+
+	timeout := errors.New("statement timeout")
+	b := NewRows([]string{"id"}).AddRow(1).WithRowError(10, timeout)
+
+	rows := b.Build()
+	if !rows.Next() {
+		t.Fatal("expected first row")
+	}
+	if rows.Next() || !errors.Is(rows.Err(), timeout) {
+		t.Fatalf("Err=%v, want %v after last row", rows.Err(), timeout)
+	}
+
+	early := b.Build()
+	early.Close()
+	if !errors.Is(early.Err(), timeout) {
+		t.Fatalf("Err after early Close=%v, want %v", early.Err(), timeout)
+	}
+}
+
+func TestRowsBuilder_CommandTag(t *testing.T) {
+	t.Parallel()
+
+	rows := NewRows([]string{"id"}).AddRow(1).WithCommandTag(pgconn.NewCommandTag("SELECT 1")).Build()
+	if tag := rows.CommandTag(); tag.String() != "" {
+		t.Fatalf("CommandTag before completion=%q", tag.String())
+	}
+	tag, err := pgx.ForEachRow(rows, []any{new(int)}, func() error { return nil })
+	if err != nil || tag.String() != "SELECT 1" || tag.RowsAffected() != 1 {
+		t.Fatalf("ForEachRow tag=%q error=%v", tag.String(), err)
+	}
+}
+
+func TestRowsBuilder_AssertClosed(t *testing.T) {
+	t.Parallel()
+
+	b := NewRows([]string{"id"}).AddRow(1)
+	closed := b.Build()
+	closed.Close()
+	b.AssertClosed(t)
+
+	drained := b.Build()
+	for drained.Next() {
+	}
+	rec := &recordingTB{TB: t}
+	rec.run(func() { b.AssertClosed(rec) })
+	if len(rec.errs) != 1 || !strings.Contains(rec.errs[0], "1 of 2 built rows were not closed") {
+		t.Fatalf("errs=%v", rec.errs)
+	}
+}
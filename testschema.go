@@ -0,0 +1,115 @@
+package neon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultTestSchemaTimeout = time.Minute
+	testSchemaDropTimeout    = 30 * time.Second
+	testSchemaNameMaxLen     = 40
+)
+
+// testSchemaConnect is a package-private seam used by tests to avoid opening
+// a real pool.
+var testSchemaConnect = Connect
+
+// testSchemaExec is a package-private seam used by tests to avoid opening a
+// real direct connection. It runs a single statement on directURL.
+var testSchemaExec = func(ctx context.Context, directURL, sql string) error {
+	conn, err := pgx.Connect(ctx, directURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+// testSchemaCurrent is a package-private seam used by tests to avoid querying
+// a real pool. It reports the schema unqualified names resolve to on pool.
+var testSchemaCurrent = func(ctx context.Context, pool *Pool) (string, error) {
+	var schema string
+	err := pool.QueryRow(ctx, "SELECT coalesce(current_schema(), '')").Scan(&schema)
+	return schema, err
+}
+
+// NewTestSchema creates a uniquely named schema for the calling test and
+// returns a *Pool whose search_path is pinned to it, so unqualified table
+// names resolve only inside the test's schema.
+//
+// The returned pool connects to the direct (non-pooled) endpoint: cfg.DirectURL,
+// or the URL derived from a Neon pooler ConnectionString. Neon's pooler does
+// not forward arbitrary startup parameters, so search_path is sent as a
+// startup parameter on the direct endpoint rather than with SET, and never
+// leaks into other clients' sessions. NewTestSchema still checks
+// current_schema() once the schema exists and fails the test if unqualified
+// names would not resolve to it. Only the test schema is on the search_path;
+// qualify objects that live in public.
+//
+// The schema is created and dropped (CASCADE) over the same direct URL.
+// Cleanup closes the pool and then drops the schema. If connecting, creating
+// the schema, or the search_path check fails, the test stops immediately and
+// any schema already created is still dropped; a failed drop is reported with
+// t.Errorf.
+func NewTestSchema(t testing.TB, cfg Config, opts ...Option) *Pool {
+	t.Helper()
+
+	timeout := cfg.ConnectTimeout + defaultTestSchemaTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	schema := testSchemaName(t.Name())
+	quoted := pgx.Identifier{schema}.Sanitize()
+
+	// Connect to the direct endpoint so the search_path startup parameter
+	// reaches Postgres instead of being dropped by the pooler.
+	_, directURL, err := buildPoolConfig(cfg, opts)
+	if err != nil {
+		t.Fatalf("neon: connect for test schema failed: %s", redactDSNs(err.Error()))
+	}
+	cfg.ConnectionString, cfg.DirectURL, cfg.ForcePoolerMode = directURL, directURL, false
+
+	opts = append(opts[:len(opts):len(opts)], withRuntimeParam("search_path", quoted))
+	pool, err := testSchemaConnect(ctx, cfg, opts...)
+	if err != nil {
+		t.Fatalf("neon: connect for test schema failed: %s", redactDSNs(err.Error()))
+	}
+
+	if err := testSchemaExec(ctx, directURL, "CREATE SCHEMA "+quoted); err != nil {
+		pool.Close()
+		t.Fatalf("neon: create test schema %s failed: %s", schema, redactDSNs(err.Error()))
+	}
+
+	t.Cleanup(func() {
+		dropCtx, cancelDrop := context.WithTimeout(context.Background(), testSchemaDropTimeout)
+		defer cancelDrop()
+		if err := testSchemaExec(dropCtx, directURL, "DROP SCHEMA IF EXISTS "+quoted+" CASCADE"); err != nil {
+			t.Errorf("neon: drop test schema %s failed: %s", schema, redactDSNs(err.Error()))
+		}
+	})
+	t.Cleanup(pool.Close)
+
+	current, err := testSchemaCurrent(ctx, pool)
+	if err != nil {
+		t.Fatalf("neon: check test schema search_path failed: %s", redactDSNs(err.Error()))
+	}
+	if current != schema {
+		// A connection that drops the search_path startup parameter
+		// would silently run the test against public.
+		t.Fatalf("neon: test schema search_path not applied: current_schema() is %q, want %q", current, schema)
+	}
+
+	return pool
+}
+
+// testSchemaName derives a lowercase, unquoted-identifier-safe schema name
+// from a test name.
+func testSchemaName(testName string) string {
+	return testResourceName(testName, '_', testSchemaNameMaxLen)
+}
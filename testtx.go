@@ -0,0 +1,107 @@
+package neon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const testTxBeginTimeout = 30 * time.Second
+
+// testTxBegin is a package-private seam used by tests to avoid opening a real
+// transaction.
+var testTxBegin = func(ctx context.Context, p *Pool) (pgx.Tx, error) {
+	return p.Begin(ctx)
+}
+
+// NewTestTx begins a transaction on pool and returns a DB bound to it. The
+// transaction is rolled back in t.Cleanup, so everything the test writes is
+// discarded without throwaway schemas or branches.
+//
+// Begin and BeginTx on the returned DB open savepoints inside the test
+// transaction, so code under test that calls WithTx commits to (or rolls back)
+// a savepoint rather than the real transaction. TxOptions passed to BeginTx
+// are ignored: isolation and access mode are fixed for the outer transaction.
+//
+// The returned DB uses a single connection and, like a pgx.Tx, is not safe for
+// concurrent use; close Rows before issuing the next statement. Close is a
+// no-op, and Ping checks the transaction's connection. The returned DB also
+// implements DBTX and BatchDB, so sqlc queries (via AsDBTX), CopyFrom, and
+// WithBatch run inside the test transaction too.
+//
+// A failed Begin stops the test; a failed rollback during cleanup is reported
+// with t.Errorf.
+func NewTestTx(t testing.TB, pool *Pool) DB {
+	t.Helper()
+
+	if pool == nil {
+		t.Fatalf("neon: NewTestTx requires a non-nil *Pool")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTxBeginTimeout)
+	defer cancel()
+
+	tx, err := testTxBegin(ctx, pool)
+	if err != nil {
+		t.Fatalf("neon: begin test transaction failed: %s", redactDSNs(err.Error()))
+	}
+
+	t.Cleanup(func() {
+		rollbackCtx, cancelRollback := context.WithTimeout(context.Background(), defaultRollbackTimeout)
+		defer cancelRollback()
+		if err := tx.Rollback(rollbackCtx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			t.Errorf("neon: roll back test transaction failed: %s", redactDSNs(err.Error()))
+		}
+	})
+
+	return &txDB{tx: tx}
+}
+
+// txDB implements DB, DBTX, and BatchDB on top of a single open transaction.
+type txDB struct {
+	tx pgx.Tx
+}
+
+var (
+	_ DBTX    = (*txDB)(nil)
+	_ BatchDB = (*txDB)(nil)
+)
+
+func (d *txDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return d.tx.Exec(ctx, sql, args...)
+}
+
+func (d *txDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return d.tx.Query(ctx, sql, args...)
+}
+
+func (d *txDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return d.tx.QueryRow(ctx, sql, args...)
+}
+
+func (d *txDB) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return d.tx.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+func (d *txDB) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return d.tx.SendBatch(ctx, b)
+}
+
+func (d *txDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return d.tx.Begin(ctx)
+}
+
+func (d *txDB) BeginTx(ctx context.Context, _ pgx.TxOptions) (pgx.Tx, error) {
+	return d.tx.Begin(ctx)
+}
+
+func (d *txDB) Ping(ctx context.Context) error {
+	_, err := d.tx.Exec(ctx, "SELECT 1")
+	return err
+}
+
+func (d *txDB) Close() {}
@@ -0,0 +1,355 @@
+package neon
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	// wsMaxControlPayload is the RFC 6455 limit for control frame payloads.
+	wsMaxControlPayload = 125
+)
+
+// webSocketTLSConfig is a package-private seam used by tests to trust a local
+// relay's certificate. It returns the TLS configuration for the wss:// hop.
+var webSocketTLSConfig = func(serverName string) *tls.Config {
+	return &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+}
+
+// WithWebSocket tunnels every connection through a WebSocket proxy over
+// HTTPS (port 443), for networks that block outbound 5432.
+//
+// endpoint is the proxy URL and must use wss://. When empty, it is derived
+// per connection as wss://<host>/v2, Neon's WebSocket proxy for the endpoint.
+// The Postgres TLS session required by sslmode still runs end to end inside
+// the tunnel, so TLS posture checks apply unchanged and the proxy hop is
+// encrypted as well.
+//
+// This relies on the proxy relaying binary WebSocket frames as a raw byte
+// stream, including the SSLRequest and the TLS handshake that follows it.
+// That is the mode Neon's serverless driver uses when forceDisablePgSSL is
+// false; the integration tests check it against a live endpoint. A proxy
+// that only accepts plaintext Postgres inside the tunnel is not supported.
+func WithWebSocket(endpoint string) Option {
+	return func(o *connectOptions) {
+		o.webSocket = true
+		o.webSocketEndpoint = endpoint
+	}
+}
+
+func validateWebSocketEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "wss" || u.Host == "" {
+		return errors.New("neon: insecure WebSocket endpoint rejected. WithWebSocket requires a wss:// URL")
+	}
+	return nil
+}
+
+// webSocketDialFunc returns a pgconn.DialFunc that opens a WebSocket tunnel
+// for addr. It is paired with a pass-through LookupFunc so addr carries the
+// hostname rather than a resolved IP.
+func webSocketDialFunc(endpoint string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		target, err := webSocketTarget(endpoint, addr)
+		if err != nil {
+			return nil, err
+		}
+		return dialWebSocket(ctx, target)
+	}
+}
+
+// webSocketTarget returns endpoint, or Neon's proxy URL for addr when endpoint
+// is empty.
+func webSocketTarget(endpoint, addr string) (string, error) {
+	if endpoint != "" {
+		return endpoint, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	return "wss://" + host + "/v2", nil
+}
+
+func passThroughLookup(_ context.Context, host string) ([]string, error) {
+	return []string{host}, nil
+}
+
+// dialWebSocket performs a TLS dial and an RFC 6455 client handshake, and
+// returns a net.Conn that carries its byte stream in binary frames.
+func dialWebSocket(ctx context.Context, endpoint string) (net.Conn, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "wss" {
+		return nil, errors.New("neon: invalid WebSocket endpoint")
+	}
+	hostport := u.Host
+	if u.Port() == "" {
+		hostport = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	var d net.Dialer
+	raw, err := d.DialContext(ctx, "tcp", hostport)
+	if err != nil {
+		return nil, err
+	}
+	conn := tls.Client(raw, webSocketTLSConfig(u.Hostname()))
+	if err := conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	br, err := webSocketHandshake(conn, u)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return &wsConn{Conn: conn, br: br, mask: true}, nil
+}
+
+func webSocketHandshake(conn net.Conn, u *url.URL) (*bufio.Reader, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.EscapedPath(), RawQuery: u.RawQuery},
+		Host:       u.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("neon: WebSocket upgrade failed (status=%d)", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		return nil, errors.New("neon: WebSocket upgrade failed (bad Sec-WebSocket-Accept)")
+	}
+	return br, nil
+}
+
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsConn adapts a WebSocket to a byte-stream net.Conn. Writes are sent as
+// single binary frames; reads concatenate data frames and answer pings.
+// mask is set on the client side, as RFC 6455 requires.
+type wsConn struct {
+	net.Conn
+	br   *bufio.Reader
+	mask bool
+
+	// remaining is the unread payload of the current data frame, and
+	// maskKey/maskPos its masking state.
+	remaining int64
+	maskKey   [4]byte
+	masked    bool
+	maskPos   int
+	readErr   error
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		if err := c.nextFrame(); err != nil {
+			c.readErr = err
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	if c.masked {
+		for i := 0; i < n; i++ {
+			p[i] ^= c.maskKey[c.maskPos%4]
+			c.maskPos++
+		}
+	}
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// nextFrame reads frame headers until a data frame with a payload is found,
+// handling control frames along the way.
+func (c *wsConn) nextFrame() error {
+	for {
+		var hdr [2]byte
+		if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+			return err
+		}
+		opcode := hdr[0] & 0x0F
+		masked := hdr[1]&0x80 != 0
+		length := int64(hdr[1] & 0x7F)
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+				return err
+			}
+			length = int64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+				return err
+			}
+			length = int64(binary.BigEndian.Uint64(ext[:]) & (1<<63 - 1))
+		}
+		var key [4]byte
+		if masked {
+			if _, err := io.ReadFull(c.br, key[:]); err != nil {
+				return err
+			}
+		}
+
+		switch opcode {
+		case wsOpContinuation, wsOpText, wsOpBinary:
+			if length == 0 {
+				continue
+			}
+			c.remaining, c.masked, c.maskKey, c.maskPos = length, masked, key, 0
+			return nil
+		case wsOpClose, wsOpPing, wsOpPong:
+			if length > wsMaxControlPayload {
+				return errors.New("neon: WebSocket control frame too large")
+			}
+			payload := make([]byte, length)
+			if _, err := io.ReadFull(c.br, payload); err != nil {
+				return err
+			}
+			if masked {
+				for i := range payload {
+					payload[i] ^= key[i%4]
+				}
+			}
+			switch opcode {
+			case wsOpClose:
+				_ = c.writeFrame(wsOpClose, payload)
+				return io.EOF
+			case wsOpPing:
+				if err := c.writeFrame(wsOpPong, payload); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("neon: unexpected WebSocket opcode %#x", opcode)
+		}
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsOpBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	maskBit := byte(0)
+	if c.mask {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	if !c.mask {
+		frame = append(frame, payload...)
+	} else {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		frame = append(frame, key[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^key[i%4])
+		}
+	}
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// Close sends a best-effort close frame and closes the underlying connection.
+func (c *wsConn) Close() error {
+	_ = c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = c.writeFrame(wsOpClose, []byte{0x03, 0xE8}) // 1000: normal closure
+
+	c.writeMu.Lock()
+	c.closed = true
+	c.writeMu.Unlock()
+	return c.Conn.Close()
+}